@@ -0,0 +1,1005 @@
+// Package emulator implements a software N8 that speaks the same serial
+// protocol as the EverDrive N8 Pro, for testing without hardware.
+package emulator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+const (
+	MEMORY_SIZE uint32 = 0x2000000
+	FLASH_SIZE  uint32 = 0x200000
+	FIFO_SIZE   uint32 = 0x10000
+	SECTOR_SIZE uint32 = 512
+	FILE_BLOCK  uint32 = 0x1000
+)
+
+const (
+	MODE_SERVICE uint8 = 0xA1
+	MODE_APP     uint8 = 0xA2
+)
+
+// Status returned by `CMD_USB_RECOV` when the current core already
+// matches the recovery copy.
+const STATUS_CORE_MATCH uint8 = 0x88
+
+// Device is a simulated N8.
+//
+// It holds the 0x2000000 byte address space, flash, RTC and an in-memory
+// FAT-like SD card, and serves the N8 serial protocol through `Serve`.
+// A new Device starts in service mode with an empty SD card.
+type Device struct {
+	Memory []uint8
+	Flash  []uint8
+	Disk   []uint8
+	Vdc    n8.Vdc
+
+	mu          sync.Mutex
+	fs          *fileSystem
+	serviceMode bool
+	status      uint8
+	rtcOffset   time.Duration
+	fpga        []uint8
+	game        string
+
+	file     *node
+	filePtr  uint32
+	fileMode uint8
+
+	dir    []*node
+	dirPtr int
+
+	fifo []uint8
+}
+
+// New returns a Device in service mode with an empty SD card.
+func New() *Device {
+	return &Device{
+		Memory:      make([]uint8, MEMORY_SIZE),
+		Flash:       make([]uint8, FLASH_SIZE),
+		Vdc:         n8.Vdc{V50: 5000, V25: 2500, V12: 1200, Vbt: 3000},
+		fs:          newFileSystem(),
+		serviceMode: true,
+	}
+}
+
+//
+// SD Card
+//
+
+// WriteFile stores data at path on the SD card, creating any missing
+// parent directories.
+func (d *Device) WriteFile(path string, data []uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parts := splitPath(path)
+	if len(parts) == 0 {
+		return fmt.Errorf("[WriteFile] invalid path: %q", path)
+	}
+
+	dir, status := d.fs.mkdirAll(strings.Join(parts[:len(parts)-1], "/"), d.now())
+	if status != FR_OK {
+		return fmt.Errorf("[WriteFile] cannot create %q: status 0x%02X", path, status)
+	}
+
+	n := dir.child(parts[len(parts)-1])
+	if n == nil {
+		n, status = d.fs.create(path, false, d.now())
+		if status != FR_OK {
+			return fmt.Errorf("[WriteFile] cannot create %q: status 0x%02X", path, status)
+		}
+	}
+	if n.dir {
+		return fmt.Errorf("[WriteFile] %q is a directory", path)
+	}
+
+	n.data = append([]uint8(nil), data...)
+	n.modTime = d.now()
+
+	return nil
+}
+
+// ReadFile returns the contents of the file at path on the SD card.
+func (d *Device) ReadFile(path string) ([]uint8, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, status := d.fs.lookup(path)
+	if status != FR_OK {
+		return nil, fmt.Errorf("[ReadFile] %q not found", path)
+	}
+	if n.dir {
+		return nil, fmt.Errorf("[ReadFile] %q is a directory", path)
+	}
+
+	return append([]uint8(nil), n.data...), nil
+}
+
+// MkdirAll creates the directory at path on the SD card, along with any
+// missing parents.
+func (d *Device) MkdirAll(path string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, status := d.fs.mkdirAll(path, d.now()); status != FR_OK {
+		return fmt.Errorf("[MkdirAll] cannot create %q: status 0x%02X", path, status)
+	}
+
+	return nil
+}
+
+//
+// State
+//
+
+// ServiceMode reports whether the device is in service mode.
+func (d *Device) ServiceMode() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.serviceMode
+}
+
+// SetServiceMode switches the device between service and app mode.
+func (d *Device) SetServiceMode(serviceMode bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.serviceMode = serviceMode
+}
+
+// FpgaData returns the last FPGA configuration loaded on the device.
+func (d *Device) FpgaData() []uint8 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]uint8(nil), d.fpga...)
+}
+
+// Game returns the SD path of the last game started from the menu.
+func (d *Device) Game() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.game
+}
+
+// now returns the current time of the device RTC.
+func (d *Device) now() time.Time {
+	return time.Now().Add(d.rtcOffset)
+}
+
+//
+// Protocol
+//
+
+// Serve runs the N8 protocol over rw until it returns an error.
+//
+// Commands are framed as `'+', ~'+', cmd, ~cmd` like the real device
+// expects, any bytes outside of a valid frame are skipped. Serve
+// returns nil when rw reaches EOF.
+func (d *Device) Serve(rw io.ReadWriter) error {
+	c := &conn{r: bufio.NewReader(rw), w: bufio.NewWriter(rw)}
+
+	for {
+		cmd, err := c.readCmd()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		d.mu.Lock()
+		err = d.exec(c, cmd)
+		d.mu.Unlock()
+		if err == nil {
+			err = c.flush()
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// exec runs a single command, its arguments are read from c.
+func (d *Device) exec(c *conn, cmd uint8) error {
+	switch cmd {
+	case n8.CMD_STATUS:
+		c.tx16(0xA500 | (uint16)(d.status))
+		return nil
+	case n8.CMD_GET_MODE:
+		if d.serviceMode {
+			c.tx8(MODE_SERVICE)
+		} else {
+			c.tx8(MODE_APP)
+		}
+		return nil
+	case n8.CMD_HARD_RESET:
+		if _, err := c.rx8(); err != nil {
+			return err
+		}
+		d.reset()
+		d.serviceMode = true
+		return nil
+	case n8.CMD_RUN_APP:
+		d.reset()
+		d.serviceMode = false
+		return nil
+	case n8.CMD_GET_VDC:
+		c.tx16(d.Vdc.V50)
+		c.tx16(d.Vdc.V25)
+		c.tx16(d.Vdc.V12)
+		c.tx16(d.Vdc.Vbt)
+		return nil
+	case n8.CMD_RTC_GET:
+		c.tx(n8.NewRtcTime(d.now()).GetVals())
+		return nil
+	case n8.CMD_RTC_SET:
+		return d.rtcSet(c)
+	case n8.CMD_FLA_RD:
+		return d.flashRead(c)
+	case n8.CMD_FLA_WR:
+		return d.flashWrite(c)
+	case n8.CMD_MEM_RD:
+		return d.memRead(c)
+	case n8.CMD_MEM_WR:
+		return d.memWrite(c)
+	case n8.CMD_MEM_SET:
+		return d.memSet(c)
+	case n8.CMD_MEM_TST:
+		return d.memTest(c)
+	case n8.CMD_MEM_CRC:
+		return d.memCrc(c)
+	case n8.CMD_FPGA_USB:
+		return d.fpgaUsb(c)
+	case n8.CMD_FPGA_SDC:
+		return d.fpgaSdc(c)
+	case n8.CMD_FPGA_FLA:
+		return d.fpgaFlash(c)
+	case n8.CMD_USB_RECOV:
+		return d.usbRecovery(c)
+	case n8.CMD_DISK_INIT:
+		d.status = FR_OK
+		return nil
+	case n8.CMD_DISK_READ:
+		return d.diskRead(c)
+	case n8.CMD_FILE_INFO:
+		return d.fileInfo(c)
+	case n8.CMD_FILE_DIR_OPEN:
+		return d.dirOpen(c)
+	case n8.CMD_FILE_DIR_READ:
+		return d.dirRead(c)
+	case n8.CMD_FILE_DIR_LD:
+		return d.dirLoad(c)
+	case n8.CMD_FILE_DIR_SIZE:
+		c.tx16((uint16)(len(d.dir)))
+		return nil
+	case n8.CMD_FILE_DIR_GET:
+		return d.dirGet(c)
+	case n8.CMD_FILE_OPEN:
+		return d.fileOpen(c)
+	case n8.CMD_FILE_READ:
+		return d.fileRead(c)
+	case n8.CMD_FILE_READ_MEM:
+		return d.fileReadMem(c)
+	case n8.CMD_FILE_WRITE:
+		return d.fileWrite(c)
+	case n8.CMD_FILE_WRITE_MEM:
+		return d.fileWriteMem(c)
+	case n8.CMD_FILE_CLOSE:
+		d.closeFile()
+		return nil
+	case n8.CMD_FILE_PTR:
+		return d.filePointer(c)
+	case n8.CMD_FILE_CRC:
+		return d.fileCrc(c)
+	case n8.CMD_FILE_DIR_MK:
+		return d.mkdir(c)
+	case n8.CMD_FILE_DEL:
+		return d.fileDelete(c)
+	}
+
+	d.status = FR_INT_ERR
+	return nil
+}
+
+// reset drops open file and directory state, like a reboot would.
+func (d *Device) reset() {
+	d.file = nil
+	d.dir = nil
+	d.fifo = nil
+	d.status = FR_OK
+}
+
+//
+// Hardware
+//
+
+func (d *Device) rtcSet(c *conn) error {
+	buf := make([]uint8, n8.RTC_DATA_SIZE)
+	if err := c.read(buf); err != nil {
+		return err
+	}
+
+	rtc, err := n8.NewRtcTimeFromSerial(buf)
+	if err != nil {
+		return err
+	}
+
+	t := time.Date(2000+bcd(rtc.Year), time.Month(bcd(rtc.Month)), bcd(rtc.Day),
+		bcd(rtc.Hour), bcd(rtc.Minute), bcd(rtc.Second), 0, time.Local)
+	d.rtcOffset = time.Until(t)
+
+	return nil
+}
+
+func (d *Device) flashRead(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+
+	c.tx(window(d.Flash, addr, length))
+	return nil
+}
+
+func (d *Device) flashWrite(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+
+	data, err := c.rxAck(length)
+	if err != nil {
+		return err
+	}
+	copyInto(d.Flash, addr, data)
+
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) usbRecovery(c *conn) error {
+	if _, err := c.rx32(); err != nil {
+		return err
+	}
+	if err := c.read(make([]uint8, 4)); err != nil {
+		return err
+	}
+
+	d.status = STATUS_CORE_MATCH
+	return nil
+}
+
+func (d *Device) diskRead(c *conn) error {
+	addr, count, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		c.tx8(FR_OK)
+		c.tx(window(d.Disk, (addr+i)*SECTOR_SIZE, SECTOR_SIZE))
+	}
+
+	return nil
+}
+
+//
+// Memory
+//
+
+func (d *Device) memRead(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	c.tx(window(d.Memory, addr, length))
+	return nil
+}
+
+func (d *Device) memWrite(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	data := make([]uint8, length)
+	if err := c.read(data); err != nil {
+		return err
+	}
+
+	if addr >= n8.ADDR_FIFO && addr < n8.ADDR_FIFO+FIFO_SIZE {
+		d.fifoWrite(c, data)
+		return nil
+	}
+
+	copyInto(d.Memory, addr, data)
+	return nil
+}
+
+func (d *Device) memSet(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	val, err := c.rx8()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < length && addr+i < (uint32)(len(d.Memory)); i++ {
+		d.Memory[addr+i] = val
+	}
+
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) memTest(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	val, err := c.rx8()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	var match uint8 = 1
+	for _, b := range window(d.Memory, addr, length) {
+		if b != val {
+			match = 0
+			break
+		}
+	}
+
+	c.tx8(match)
+	return nil
+}
+
+func (d *Device) memCrc(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	init, err := c.rx32()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	c.tx32(crc32.Update(init, crc32.IEEETable, window(d.Memory, addr, length)))
+	return nil
+}
+
+//
+// FPGA
+//
+
+func (d *Device) fpgaUsb(c *conn) error {
+	length, err := c.rx32()
+	if err != nil {
+		return err
+	}
+
+	data, err := c.rxAck(length)
+	if err != nil {
+		return err
+	}
+
+	d.fpga = data
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) fpgaSdc(c *conn) error {
+	length, err := c.rx32()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	if d.file == nil {
+		d.status = FR_INVALID_OBJECT
+		return nil
+	}
+
+	d.fpga = d.readFile(length)
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) fpgaFlash(c *conn) error {
+	addr, err := c.rx32()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	if addr >= FLASH_SIZE {
+		d.status = FR_INT_ERR
+		return nil
+	}
+
+	d.fpga = window(d.Flash, addr, FLASH_SIZE-addr)
+	d.status = FR_OK
+	return nil
+}
+
+//
+// Menu FIFO
+//
+
+// fifoWrite feeds data written to the FIFO to the menu.
+//
+// The menu only runs in app mode, in service mode FIFO data is dropped.
+func (d *Device) fifoWrite(c *conn, data []uint8) {
+	if d.serviceMode {
+		return
+	}
+
+	d.fifo = append(d.fifo, data...)
+
+	for len(d.fifo) >= 2 {
+		if d.fifo[0] != n8.CMD_PREFIX {
+			d.fifo = d.fifo[1:]
+			continue
+		}
+
+		switch d.fifo[1] {
+		case n8.CMD_REBOOT, n8.CMD_HALT:
+			d.fifo = d.fifo[2:]
+			c.tx8(0)
+		case n8.CMD_RUN_GAME:
+			d.fifo = d.fifo[2:]
+		case n8.CMD_SELECT_GAME:
+			if len(d.fifo) < 4 {
+				return
+			}
+			length := (int)(binary.LittleEndian.Uint16(d.fifo[2:4]))
+			if len(d.fifo) < 4+length {
+				return
+			}
+			d.selectGame(c, string(d.fifo[4:4+length]))
+			d.fifo = d.fifo[4+length:]
+		default:
+			d.fifo = d.fifo[2:]
+		}
+	}
+}
+
+// selectGame replies with the mapper of the selected iNES ROM.
+func (d *Device) selectGame(c *conn, path string) {
+	n, status := d.fs.lookup(path)
+	if status != FR_OK || n.dir {
+		c.tx8(FR_NO_FILE)
+		return
+	}
+
+	var mapper uint16
+	if len(n.data) >= 16 {
+		mapper = (uint16)(n.data[6]>>4) | (uint16)(n.data[7]&0xf0)
+	}
+
+	d.game = path
+	c.tx8(0)
+	c.tx16(mapper)
+}
+
+//
+// Files
+//
+
+func (d *Device) fileInfo(c *conn) error {
+	path, err := c.rxString()
+	if err != nil {
+		return err
+	}
+
+	n, status := d.fs.lookup(path)
+	if status == FR_OK && n == d.fs.root {
+		status = FR_INVALID_NAME
+	}
+	c.tx8(status)
+	if status == FR_OK {
+		c.txFileInfo(n, 0xffff)
+	}
+
+	return nil
+}
+
+func (d *Device) dirOpen(c *conn) error {
+	path, err := c.rxString()
+	if err != nil {
+		return err
+	}
+
+	d.dir, d.status = d.fs.list(path, false)
+	d.dirPtr = 0
+
+	return nil
+}
+
+func (d *Device) dirRead(c *conn) error {
+	maxNameLength, err := c.rx16()
+	if err != nil {
+		return err
+	}
+
+	if d.dirPtr >= len(d.dir) {
+		c.tx8(FR_NO_FILE)
+		return nil
+	}
+
+	c.tx8(FR_OK)
+	c.txFileInfo(d.dir[d.dirPtr], maxNameLength)
+	d.dirPtr++
+
+	return nil
+}
+
+func (d *Device) dirLoad(c *conn) error {
+	sorted, err := c.rx8()
+	if err != nil {
+		return err
+	}
+	path, err := c.rxString()
+	if err != nil {
+		return err
+	}
+
+	d.dir, d.status = d.fs.list(path, sorted != 0)
+	d.dirPtr = 0
+
+	return nil
+}
+
+func (d *Device) dirGet(c *conn) error {
+	start, err := c.rx16()
+	if err != nil {
+		return err
+	}
+	amount, err := c.rx16()
+	if err != nil {
+		return err
+	}
+	maxNameLength, err := c.rx16()
+	if err != nil {
+		return err
+	}
+
+	for i := (int)(start); i < (int)(start)+(int)(amount); i++ {
+		if i >= len(d.dir) {
+			c.tx8(FR_NO_FILE)
+			return nil
+		}
+		c.tx8(FR_OK)
+		c.txFileInfo(d.dir[i], maxNameLength)
+	}
+
+	return nil
+}
+
+func (d *Device) fileOpen(c *conn) error {
+	mode, err := c.rx8()
+	if err != nil {
+		return err
+	}
+	path, err := c.rxString()
+	if err != nil {
+		return err
+	}
+
+	d.file = nil
+	d.status = d.openFile(path, mode)
+
+	return nil
+}
+
+// openFile opens path following FatFs `f_open` mode semantics.
+func (d *Device) openFile(path string, mode uint8) uint8 {
+	n, status := d.fs.lookup(path)
+	if status == FR_NO_PATH {
+		return status
+	}
+	if n != nil && n.dir {
+		return FR_NO_FILE
+	}
+
+	switch {
+	case mode&n8.FAT_CREATE_NEW != 0:
+		if n != nil {
+			return FR_EXIST
+		}
+	case mode&(n8.FAT_CREATE_ALWAYS|n8.FAT_OPEN_ALWAYS) != 0:
+	default:
+		if n == nil {
+			return FR_NO_FILE
+		}
+	}
+
+	if mode&n8.FAT_WRITE != 0 && n != nil && n.attr.ReadOnly() {
+		return FR_DENIED
+	}
+
+	if n == nil {
+		n, status = d.fs.create(path, false, d.now())
+		if status != FR_OK {
+			return status
+		}
+	}
+	if mode&n8.FAT_CREATE_ALWAYS != 0 {
+		n.data = nil
+		n.modTime = d.now()
+	}
+
+	d.file = n
+	d.fileMode = mode
+	d.filePtr = 0
+	if mode&n8.FAT_OPEN_APPEND == n8.FAT_OPEN_APPEND {
+		d.filePtr = n.size()
+	}
+
+	return FR_OK
+}
+
+func (d *Device) closeFile() {
+	if d.file == nil {
+		d.status = FR_INVALID_OBJECT
+		return
+	}
+
+	d.file = nil
+	d.status = FR_OK
+}
+
+// readFile reads up to length bytes from the open file and advances the
+// file pointer.
+func (d *Device) readFile(length uint32) []uint8 {
+	size := d.file.size()
+	if d.filePtr >= size {
+		return nil
+	}
+
+	n := min(length, size-d.filePtr)
+	data := append([]uint8(nil), d.file.data[d.filePtr:d.filePtr+n]...)
+	d.filePtr += n
+
+	return data
+}
+
+// writeFile writes data at the file pointer and advances it.
+func (d *Device) writeFile(data []uint8) {
+	end := d.filePtr + (uint32)(len(data))
+	if end > (uint32)(len(d.file.data)) {
+		grown := make([]uint8, end)
+		copy(grown, d.file.data)
+		d.file.data = grown
+	}
+
+	copy(d.file.data[d.filePtr:], data)
+	d.filePtr = end
+	d.file.modTime = d.now()
+}
+
+func (d *Device) fileRead(c *conn) error {
+	length, err := c.rx32()
+	if err != nil {
+		return err
+	}
+
+	if d.file == nil || d.fileMode&n8.FAT_READ == 0 {
+		c.tx8(FR_DENIED)
+		return nil
+	}
+
+	for length > 0 {
+		block := min(length, FILE_BLOCK)
+
+		c.tx8(FR_OK)
+		c.tx(padded(d.readFile(block), block))
+
+		length -= block
+	}
+
+	return nil
+}
+
+func (d *Device) fileReadMem(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	if d.file == nil || d.fileMode&n8.FAT_READ == 0 {
+		d.status = FR_DENIED
+		return nil
+	}
+
+	copyInto(d.Memory, addr, padded(d.readFile(length), length))
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) fileWrite(c *conn) error {
+	length, err := c.rx32()
+	if err != nil {
+		return err
+	}
+
+	if d.file == nil || d.fileMode&n8.FAT_WRITE == 0 {
+		c.tx8(FR_DENIED)
+		d.status = FR_DENIED
+		return nil
+	}
+
+	data, err := c.rxAck(length)
+	if err != nil {
+		return err
+	}
+
+	d.writeFile(data)
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) fileWriteMem(c *conn) error {
+	addr, length, err := c.rxAddrLen()
+	if err != nil {
+		return err
+	}
+	if _, err := c.rx8(); err != nil {
+		return err
+	}
+
+	if d.file == nil || d.fileMode&n8.FAT_WRITE == 0 {
+		d.status = FR_DENIED
+		return nil
+	}
+
+	d.writeFile(padded(window(d.Memory, addr, length), length))
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) filePointer(c *conn) error {
+	addr, err := c.rx32()
+	if err != nil {
+		return err
+	}
+
+	if d.file == nil {
+		d.status = FR_INVALID_OBJECT
+		return nil
+	}
+
+	if addr > d.file.size() {
+		if d.fileMode&n8.FAT_WRITE != 0 {
+			grown := make([]uint8, addr)
+			copy(grown, d.file.data)
+			d.file.data = grown
+		} else {
+			addr = d.file.size()
+		}
+	}
+
+	d.filePtr = addr
+	d.status = FR_OK
+	return nil
+}
+
+func (d *Device) fileCrc(c *conn) error {
+	length, err := c.rx32()
+	if err != nil {
+		return err
+	}
+	init, err := c.rx32()
+	if err != nil {
+		return err
+	}
+
+	if d.file == nil || d.fileMode&n8.FAT_READ == 0 {
+		c.tx8(FR_DENIED)
+		return nil
+	}
+
+	c.tx8(FR_OK)
+	c.tx32(crc32.Update(init, crc32.IEEETable, d.readFile(length)))
+	return nil
+}
+
+func (d *Device) mkdir(c *conn) error {
+	path, err := c.rxString()
+	if err != nil {
+		return err
+	}
+
+	_, d.status = d.fs.create(path, true, d.now())
+	return nil
+}
+
+func (d *Device) fileDelete(c *conn) error {
+	path, err := c.rxString()
+	if err != nil {
+		return err
+	}
+
+	d.status = d.fs.remove(path)
+	return nil
+}
+
+//
+// Misc
+//
+
+// window returns length bytes of buf starting at addr, zero filled past
+// the end of buf.
+func window(buf []uint8, addr uint32, length uint32) []uint8 {
+	data := make([]uint8, length)
+	if addr < (uint32)(len(buf)) {
+		copy(data, buf[addr:])
+	}
+
+	return data
+}
+
+// padded zero pads data to length bytes.
+func padded(data []uint8, length uint32) []uint8 {
+	if (uint32)(len(data)) >= length {
+		return data
+	}
+
+	buf := make([]uint8, length)
+	copy(buf, data)
+	return buf
+}
+
+// copyInto copies data into buf at addr, dropping anything past the end.
+func copyInto(buf []uint8, addr uint32, data []uint8) {
+	if addr < (uint32)(len(buf)) {
+		copy(buf[addr:], data)
+	}
+}
+
+// bcd decodes a BCD byte as written by `n8.NewRtcTime`.
+func bcd(val uint8) int {
+	return (int)(val>>4)*10 + (int)(val&0x0f)
+}
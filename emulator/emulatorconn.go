@@ -0,0 +1,158 @@
+package emulator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// conn is the device side of the serial link.
+//
+// Replies are buffered and flushed whenever the device waits for more
+// data from the host, or once a command is done.
+type conn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// readCmd waits for the next `'+', ~'+', cmd, ~cmd` frame and returns
+// the command byte.
+func (c *conn) readCmd() (uint8, error) {
+	if err := c.flush(); err != nil {
+		return 0, err
+	}
+
+	var frame [4]uint8
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		copy(frame[:], frame[1:])
+		frame[3] = b
+
+		if frame[0] == '+' && frame[1] == '+'^0xff && frame[2] == frame[3]^0xff {
+			frame = [4]uint8{}
+			return b ^ 0xff, nil
+		}
+	}
+}
+
+func (c *conn) flush() error {
+	return c.w.Flush()
+}
+
+// read fills buf with data from the host.
+func (c *conn) read(buf []uint8) error {
+	if c.r.Buffered() < len(buf) {
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.ReadFull(c.r, buf)
+	return err
+}
+
+func (c *conn) rx8() (uint8, error) {
+	var buf [1]uint8
+	err := c.read(buf[:])
+	return buf[0], err
+}
+
+func (c *conn) rx16() (uint16, error) {
+	var buf [2]uint8
+	err := c.read(buf[:])
+	return binary.LittleEndian.Uint16(buf[:]), err
+}
+
+func (c *conn) rx32() (uint32, error) {
+	var buf [4]uint8
+	err := c.read(buf[:])
+	return binary.LittleEndian.Uint32(buf[:]), err
+}
+
+func (c *conn) rxString() (string, error) {
+	length, err := c.rx16()
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]uint8, length)
+	err = c.read(buf)
+	return string(buf), err
+}
+
+// rxAddrLen reads the address and length arguments most commands start with.
+func (c *conn) rxAddrLen() (uint32, uint32, error) {
+	addr, err := c.rx32()
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := c.rx32()
+	return addr, length, err
+}
+
+// rxAck receives data sent with `n8.TxDataACK`, acking every block.
+func (c *conn) rxAck(length uint32) ([]uint8, error) {
+	data := make([]uint8, length)
+
+	for offset := uint32(0); offset < length; offset += n8.ACK_BLOCK_SIZE {
+		block := min(n8.ACK_BLOCK_SIZE, length-offset)
+
+		c.tx8(0)
+		if err := c.read(data[offset : offset+block]); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func (c *conn) tx(buf []uint8) {
+	c.w.Write(buf)
+}
+
+func (c *conn) tx8(val uint8) {
+	c.w.WriteByte(val)
+}
+
+func (c *conn) tx16(val uint16) {
+	var buf [2]uint8
+	binary.LittleEndian.PutUint16(buf[:], val)
+	c.tx(buf[:])
+}
+
+func (c *conn) tx32(val uint32) {
+	var buf [4]uint8
+	binary.LittleEndian.PutUint32(buf[:], val)
+	c.tx(buf[:])
+}
+
+func (c *conn) txString(str string) {
+	c.tx16((uint16)(len(str)))
+	c.tx([]uint8(str))
+}
+
+// txFileInfo sends an entry the way `n8.RxFileInfo` reads it.
+func (c *conn) txFileInfo(n *node, maxNameLength uint16) {
+	date, clock := n8.EncodeFatTime(n.modTime)
+	if n.modTime.IsZero() {
+		date, clock = n8.EncodeFatTime(time.Now())
+	}
+
+	name := n.name
+	if maxNameLength != 0 && len(name) > (int)(maxNameLength) {
+		name = name[:maxNameLength]
+	}
+
+	c.tx32(n.size())
+	c.tx16(date)
+	c.tx16(clock)
+	c.tx8((uint8)(n.attr))
+	c.txString(name)
+}
@@ -0,0 +1,208 @@
+package emulator
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// FatFs result codes, as returned by the N8 for file system commands.
+const (
+	FR_OK             uint8 = 0x00
+	FR_DISK_ERR       uint8 = 0x01
+	FR_INT_ERR        uint8 = 0x02
+	FR_NOT_READY      uint8 = 0x03
+	FR_NO_FILE        uint8 = 0x04
+	FR_NO_PATH        uint8 = 0x05
+	FR_INVALID_NAME   uint8 = 0x06
+	FR_DENIED         uint8 = 0x07
+	FR_EXIST          uint8 = 0x08
+	FR_INVALID_OBJECT uint8 = 0x09
+)
+
+type node struct {
+	name     string
+	dir      bool
+	attr     n8.Attr
+	modTime  time.Time
+	data     []uint8
+	children []*node
+}
+
+type fileSystem struct {
+	root *node
+}
+
+func newFileSystem() *fileSystem {
+	return &fileSystem{root: &node{dir: true, attr: n8.ATTR_DIR}}
+}
+
+// splitPath splits an SD path into its elements.
+//
+// Both `/` and `\` are accepted as separators, empty and `.` elements
+// are dropped so "", "/" and "." all refer to the root directory.
+func splitPath(path string) []string {
+	path = strings.ReplaceAll(path, "\\", "/")
+
+	var parts []string
+	for _, p := range strings.Split(path, "/") {
+		if p != "" && p != "." {
+			parts = append(parts, p)
+		}
+	}
+
+	return parts
+}
+
+// child returns the entry of a directory matching name, ignoring case
+// like FAT does.
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// size returns the size reported for the entry, 0 for directories.
+func (n *node) size() uint32 {
+	if n.dir {
+		return 0
+	}
+
+	return (uint32)(len(n.data))
+}
+
+// lookup finds the entry at path.
+func (fs *fileSystem) lookup(path string) (*node, uint8) {
+	n := fs.root
+	parts := splitPath(path)
+
+	for i, p := range parts {
+		if !n.dir {
+			return nil, FR_NO_PATH
+		}
+		n = n.child(p)
+		if n == nil {
+			if i == len(parts)-1 {
+				return nil, FR_NO_FILE
+			}
+			return nil, FR_NO_PATH
+		}
+	}
+
+	return n, FR_OK
+}
+
+// parent finds the directory that contains path, along with the name
+// of the last path element.
+func (fs *fileSystem) parent(path string) (*node, string, uint8) {
+	parts := splitPath(path)
+	if len(parts) == 0 {
+		return nil, "", FR_INVALID_NAME
+	}
+
+	dir, status := fs.lookup(strings.Join(parts[:len(parts)-1], "/"))
+	if status != FR_OK {
+		return nil, "", FR_NO_PATH
+	}
+	if !dir.dir {
+		return nil, "", FR_NO_PATH
+	}
+
+	return dir, parts[len(parts)-1], FR_OK
+}
+
+// create adds a new empty file or directory at path.
+func (fs *fileSystem) create(path string, dir bool, modTime time.Time) (*node, uint8) {
+	parent, name, status := fs.parent(path)
+	if status != FR_OK {
+		return nil, status
+	}
+	if parent.child(name) != nil {
+		return nil, FR_EXIST
+	}
+
+	n := &node{name: name, dir: dir, modTime: modTime}
+	if dir {
+		n.attr = n8.ATTR_DIR
+	} else {
+		n.attr = n8.ATTR_ARC
+	}
+	parent.children = append(parent.children, n)
+
+	return n, FR_OK
+}
+
+// mkdirAll creates the directory at path along with any missing parents.
+func (fs *fileSystem) mkdirAll(path string, modTime time.Time) (*node, uint8) {
+	n := fs.root
+	for _, p := range splitPath(path) {
+		c := n.child(p)
+		if c == nil {
+			c = &node{name: p, dir: true, attr: n8.ATTR_DIR, modTime: modTime}
+			n.children = append(n.children, c)
+		}
+		if !c.dir {
+			return nil, FR_EXIST
+		}
+		n = c
+	}
+
+	return n, FR_OK
+}
+
+// remove deletes the file or empty directory at path.
+func (fs *fileSystem) remove(path string) uint8 {
+	parent, name, status := fs.parent(path)
+	if status != FR_OK {
+		return status
+	}
+
+	for i, c := range parent.children {
+		if strings.EqualFold(c.name, name) {
+			if c.dir && len(c.children) != 0 {
+				return FR_DENIED
+			}
+			if c.attr.ReadOnly() {
+				return FR_DENIED
+			}
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			return FR_OK
+		}
+	}
+
+	return FR_NO_FILE
+}
+
+// list returns the entries of the directory at path.
+//
+// When sorted is set directories are listed first, then files, each
+// ordered by name.
+func (fs *fileSystem) list(path string, sorted bool) ([]*node, uint8) {
+	dir, status := fs.lookup(path)
+	if status != FR_OK {
+		return nil, FR_NO_PATH
+	}
+	if !dir.dir {
+		return nil, FR_NO_PATH
+	}
+
+	entries := make([]*node, len(dir.children))
+	copy(entries, dir.children)
+
+	if sorted {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].dir != entries[j].dir {
+				return entries[i].dir
+			}
+			return strings.ToLower(entries[i].name) < strings.ToLower(entries[j].name)
+		})
+	}
+
+	return entries, FR_OK
+}
@@ -0,0 +1,82 @@
+package emulator
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Pty is a pseudo-terminal a Device can be served on, so that tools
+// expecting a serial device can talk to the emulator.
+type Pty struct {
+	Path   string
+	master *os.File
+	slave  *os.File
+}
+
+// OpenPty opens a new pseudo-terminal.
+//
+// The slave side is put in raw mode and kept open, so clients can close
+// and reopen `Path` (as `bootWait` does) without hanging up the master.
+func OpenPty() (*Pty, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("[OpenPty] %w", err)
+	}
+
+	fd := (int)(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("[OpenPty] unlock failed: %w", err)
+	}
+	ptn, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("[OpenPty] could not get pty number: %w", err)
+	}
+
+	path := fmt.Sprintf("/dev/pts/%d", ptn)
+	slave, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("[OpenPty] %w", err)
+	}
+
+	if err := makeRaw((int)(slave.Fd())); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, fmt.Errorf("[OpenPty] %w", err)
+	}
+
+	return &Pty{Path: path, master: master, slave: slave}, nil
+}
+
+// Serve runs the N8 protocol for d on the pseudo-terminal.
+func (p *Pty) Serve(d *Device) error {
+	return d.Serve(p.master)
+}
+
+// Close closes both sides of the pseudo-terminal.
+func (p *Pty) Close() error {
+	p.slave.Close()
+	return p.master.Close()
+}
+
+// makeRaw disables echo, line editing and byte translation on a terminal.
+func makeRaw(fd int) error {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+
+	return unix.IoctlSetTermios(fd, unix.TCSETS, termios)
+}
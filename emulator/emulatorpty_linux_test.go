@@ -0,0 +1,63 @@
+package emulator_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/emulator"
+	"forge.rights.ninja/jeff/goedlink/n8"
+	"forge.rights.ninja/jeff/goedlink/nesrom"
+)
+
+func TestPty(t *testing.T) {
+	if _, err := os.Stat("/dev/ptmx"); err != nil {
+		t.Skip("no pseudo-terminals:", err)
+	}
+
+	device := emulator.New()
+	pty, err := emulator.OpenPty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- pty.Serve(device) }()
+	defer func() {
+		pty.Close()
+		<-served
+	}()
+
+	// the same way the other commands open the N8 given with -d
+	transport, err := n8.OpenSerial(pty.Path, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	dev := n8.NewN8(transport)
+
+	ok, status, err := dev.GetStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || status != 0 {
+		t.Fatalf("GetStatus = %t, %d, want the a5 header and status 0", ok, status)
+	}
+
+	// every byte value, including the ones a terminal not in raw mode
+	// would translate or act on
+	data := make([]uint8, 0x1000)
+	for i := range data {
+		data[i] = (uint8)(i)
+	}
+	if err := dev.WriteMemory(nesrom.ADDR_PRG, data, (uint32)(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]uint8, len(data))
+	if err := dev.ReadMemory(nesrom.ADDR_PRG, got, (uint32)(len(got))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("memory read back over the pty does not match")
+	}
+}
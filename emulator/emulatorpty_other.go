@@ -0,0 +1,28 @@
+//go:build !linux
+
+package emulator
+
+import "fmt"
+
+// Pty is a pseudo-terminal a Device can be served on, so that tools
+// expecting a serial device can talk to the emulator.
+//
+// Pseudo-terminals are only supported on Linux.
+type Pty struct {
+	Path string
+}
+
+// OpenPty opens a new pseudo-terminal.
+func OpenPty() (*Pty, error) {
+	return nil, fmt.Errorf("[OpenPty] pseudo-terminals are only supported on Linux")
+}
+
+// Serve runs the N8 protocol for d on the pseudo-terminal.
+func (p *Pty) Serve(d *Device) error {
+	return fmt.Errorf("[Serve] pseudo-terminals are only supported on Linux")
+}
+
+// Close closes both sides of the pseudo-terminal.
+func (p *Pty) Close() error {
+	return nil
+}
@@ -0,0 +1,194 @@
+package emulator
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+var _ n8.Transport = (*Transport)(nil)
+
+var errClosed = errors.New("emulator transport is closed")
+
+// Transport connects an `n8.N8` to a Device running in the same process.
+//
+// It implements `n8.Transport`. Like a serial port, reads return whatever
+// the device has sent so far and give up with `io.EOF` once the read
+// timeout passes without any data.
+type Transport struct {
+	// Latency is added to every Read, to mimic the round trip of a real
+	// USB serial link.
+	Latency time.Duration
+
+	toDevice   *pipe
+	fromDevice *pipe
+
+	mu      sync.Mutex
+	timeout time.Duration
+	closed  bool
+	done    chan struct{}
+}
+
+// NewTransport starts serving d and returns a Transport connected to it.
+//
+// The device keeps running across `Close` and `Reopen`, call `Shutdown`
+// to stop it.
+func NewTransport(d *Device) *Transport {
+	t := &Transport{
+		toDevice:   newPipe(),
+		fromDevice: newPipe(),
+		timeout:    time.Second * 2,
+		done:       make(chan struct{}),
+	}
+
+	go func() {
+		defer close(t.done)
+		d.Serve(&deviceSide{t})
+	}()
+
+	return t
+}
+
+// Read reads data sent by the device.
+func (t *Transport) Read(buf []uint8) (int, error) {
+	t.mu.Lock()
+	closed, timeout, latency := t.closed, t.timeout, t.Latency
+	t.mu.Unlock()
+
+	if closed {
+		return 0, errClosed
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	return t.fromDevice.read(buf, timeout)
+}
+
+// Write sends data to the device.
+func (t *Transport) Write(buf []uint8) (int, error) {
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+
+	if closed {
+		return 0, errClosed
+	}
+
+	return t.toDevice.write(buf)
+}
+
+// Close closes the host side of the link, the device keeps running.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	return nil
+}
+
+// Reopen reopens the host side of the link with a new read timeout.
+func (t *Transport) Reopen(timeout time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = false
+	t.timeout = timeout
+	return nil
+}
+
+// Shutdown stops the device and waits for it to finish serving.
+func (t *Transport) Shutdown() {
+	t.Close()
+	t.toDevice.close()
+	t.fromDevice.close()
+	<-t.done
+}
+
+// deviceSide is the end of the link handed to `Device.Serve`.
+type deviceSide struct {
+	t *Transport
+}
+
+func (s *deviceSide) Read(buf []uint8) (int, error) {
+	return s.t.toDevice.read(buf, 0)
+}
+
+func (s *deviceSide) Write(buf []uint8) (int, error) {
+	return s.t.fromDevice.write(buf)
+}
+
+// pipe is an unbounded byte queue with optional read timeouts.
+type pipe struct {
+	mu     sync.Mutex
+	buf    []uint8
+	notify chan struct{}
+	closed bool
+}
+
+func newPipe() *pipe {
+	return &pipe{notify: make(chan struct{})}
+}
+
+// read waits for data and reads as much as is available into buf.
+//
+// A timeout of 0 waits until data arrives or the pipe is closed.
+func (p *pipe) read(buf []uint8, timeout time.Duration) (int, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		p.mu.Lock()
+		if len(p.buf) > 0 {
+			n := copy(buf, p.buf)
+			p.buf = p.buf[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		notify := p.notify
+		p.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-deadline:
+			return 0, io.EOF
+		}
+	}
+}
+
+func (p *pipe) write(buf []uint8) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	p.buf = append(p.buf, buf...)
+	close(p.notify)
+	p.notify = make(chan struct{})
+
+	return len(buf), nil
+}
+
+func (p *pipe) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.notify)
+		p.notify = make(chan struct{})
+	}
+}
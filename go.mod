@@ -4,4 +4,4 @@ go 1.21.6
 
 require github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
 
-require golang.org/x/sys v0.21.0 // indirect
+require golang.org/x/sys v0.21.0
@@ -2,127 +2,292 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	iofs "io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strings"
+	"syscall"
 	"time"
 
+	"forge.rights.ninja/jeff/goedlink/emulator"
 	"forge.rights.ninja/jeff/goedlink/n8"
 	"forge.rights.ninja/jeff/goedlink/nesrom"
 )
 
-var commands = map[string]func([]string){
+var commands = map[string]func([]string) error{
 	"appmode":     AppMode,
 	"cp":          Copy,
+	"devices":     Devices,
+	"emulate":     Emulate,
 	"info":        Info,
 	"initfpga":    InitFpga,
 	"getrtc":      GetRtc,
 	"loadrom":     LoadRom,
+	"ls":          List,
 	"mkdir":       MakeDirectory,
 	"readmemory":  ReadMemory,
 	"reboot":      Reboot,
 	"recovery":    Recovery,
+	"rm":          Remove,
+	"rominfo":     RomInfo,
+	"reset-link":  ResetLink,
 	"servicemode": ServiceMode,
 	"setrtc":      SetRtc,
+	"sync":        Sync,
 	"writeflash":  WriteFlash,
 	"writememory": WriteMemory,
 }
 
 var N8 n8.N8
 
+var progress = newProgressBar(os.Stderr)
+
+// ctx is cancelled on the first interrupt, a second one exits
+// immediately.
+var ctx = context.Background()
+
 // AppMode switches the N8 out of service mode
-func AppMode(args []string) {
+func AppMode(args []string) error {
 	fs := flag.NewFlagSet("appmode", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
 		fmt.Println("[App Mode]")
-		N8.ExitServiceMode()
+		if err := N8.ExitServiceMode(); err != nil {
+			return err
+		}
 		fmt.Println("[App Mode] ok")
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // Copy copies a file on the N8.
 //
 // Prefix the source or destination string with `sd:` to specify a location on the N8 SD card.
-func Copy(args []string) {
+// A source of `-` reads stdin and a destination of `-` writes to stdout.
+func Copy(args []string) error {
 	fs := flag.NewFlagSet("copy", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
-	source := fs.String("source", "", "path to copy from, prefix with `sd:` for file on the SD card")
-	destination := fs.String("destination", "", "path to copy to, prefix with `sd:` for destination on the SD card")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
+	source := fs.String("source", "", "path to copy from, prefix with `sd:` for file on the SD card, - for stdin")
+	destination := fs.String("destination", "", "path to copy to, prefix with `sd:` for destination on the SD card, - for stdout")
+	verify := fs.Bool("verify", false, "(optional) check the CRC of each file after the transfer and retry on a mismatch")
+	resume := fs.Bool("resume", false, "(optional) continue an interrupted copy, keeping the part of the destination that matches the source")
 	fs.Parse(args)
 
-	if *device != "" && *source != "" && *destination != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
-
-		N8.CopyFile(*source, *destination)
-		fmt.Printf("[Copy] \"%s\" copied to \"%s\"\n", *source, *destination)
-		os.Exit(0)
+	if !*help && *source != "" && *destination != "" {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
+		N8.Verify = *verify
+		N8.Resume = *resume
+
+		switch {
+		case *source == "-":
+			if !strings.HasPrefix(strings.ToLower(*destination), "sd:") {
+				return fmt.Errorf("[Copy] stdin can only be copied to the SD card")
+			}
+			n, err := N8.WriteFileFrom((*destination)[3:], os.Stdin)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("[Copy] %d bytes copied from stdin to \"%s\"\n", n, *destination)
+		case *destination == "-":
+			if !strings.HasPrefix(strings.ToLower(*source), "sd:") {
+				return fmt.Errorf("[Copy] only files on the SD card can be copied to stdout")
+			}
+			if _, err := N8.ReadFileTo((*source)[3:], os.Stdout); err != nil {
+				return err
+			}
+		default:
+			if err := N8.CopyFile(*source, *destination); err != nil {
+				return err
+			}
+			fmt.Printf("[Copy] \"%s\" copied to \"%s\"\n", *source, *destination)
+		}
+		return nil
 	}
 
 	fs.Usage()
+	return nil
+}
+
+// Devices lists serial devices that look like an N8.
+//
+// Each candidate is probed with `CMD_STATUS` and reported along with the
+// mode it is in.
+func Devices(args []string) error {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	fs.Parse(args)
+
+	if *help {
+		fs.Usage()
+		return nil
+	}
+
+	devices, err := n8.Discover()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("[Devices]")
+	if len(devices) == 0 {
+		fmt.Println(" no N8 found")
+	}
+	for _, device := range devices {
+		switch {
+		case !device.Found:
+			fmt.Printf(" %s: no response\n", device.Path)
+		case device.ServiceMode:
+			fmt.Printf(" %s: N8 (service mode)\n", device.Path)
+		default:
+			fmt.Printf(" %s: N8 (app mode)\n", device.Path)
+		}
+	}
+
+	return nil
+}
+
+// Emulate serves a simulated N8 on a pseudo-terminal.
+//
+// Prints the path of the pseudo-terminal, which can be passed to any
+// other command with `-d`. Optionally preloads the SD card from a
+// folder on the host.
+func Emulate(args []string) error {
+	fs := flag.NewFlagSet("emulate", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	sdPath := fs.String("sd", "", "(optional) folder to copy onto the emulated SD card")
+	fs.Parse(args)
+
+	if *help {
+		fs.Usage()
+		return nil
+	}
+
+	device := emulator.New()
+	if *sdPath != "" {
+		err := filepath.WalkDir(*sdPath, func(path string, entry iofs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(*sdPath, path)
+			if err != nil || rel == "." {
+				return err
+			}
+			if entry.IsDir() {
+				return device.MkdirAll(filepath.ToSlash(rel))
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return device.WriteFile(filepath.ToSlash(rel), data)
+		})
+		if err != nil {
+			return fmt.Errorf("[emulate] error loading SD card from %s: %w", *sdPath, err)
+		}
+	}
+
+	pty, err := emulator.OpenPty()
+	if err != nil {
+		return err
+	}
+	defer pty.Close()
+
+	go func() {
+		<-ctx.Done()
+		pty.Close()
+	}()
+
+	fmt.Printf("[Emulate] serving N8 on %s\n", pty.Path)
+	if err := pty.Serve(device); err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	return nil
 }
 
 // Info prints the current configuration from the N8.
-func Info(args []string) {
+func Info(args []string) error {
 	fs := flag.NewFlagSet("info", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
-		N8.ExitServiceMode()
-		config := N8.GetConfig()
+		if err := N8.ExitServiceMode(); err != nil {
+			return err
+		}
+		config, err := N8.GetConfig()
+		if err != nil {
+			return err
+		}
 		fmt.Println("[Info]")
 		config.PrintFull()
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // InitFpga initializes N8 FPGA.
 //
 // Reads FPGA init data from file and writes to FPGA.
-func InitFpga(args []string) {
+func InitFpga(args []string) error {
 	fs := flag.NewFlagSet("initfpga", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	path := fs.String("path", "", "(optional) read data from a file (otherwise data is read from standard input)")
 	length := fs.Int64("length", 0, "(required) number of bytes to read (eg. '0x40', '64', etc)")
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
 		var buf []uint8
 		if *path != "" {
 			file, err := os.ReadFile(*path)
 			if err != nil {
-				log.Fatalf("[fpgaInit] error reading file %s: %v", *path, err)
+				return fmt.Errorf("[fpgaInit] error reading file %s: %w", *path, err)
 			}
 			buf = make([]uint8, len(file))
 			copy(buf, file)
 		} else {
 			if *length == 0 {
-				log.Fatalln("[fpgaInit] length must be specified when reading from standard input")
+				return fmt.Errorf("[fpgaInit] length must be specified when reading from standard input")
 			}
 			buf = make([]uint8, *length)
 			reader := bufio.NewReader(os.Stdin)
@@ -133,111 +298,216 @@ func InitFpga(args []string) {
 					if err == io.EOF {
 						break
 					}
-					fmt.Println("[writeMemory] error reading input:", err)
-					return
+					return fmt.Errorf("[fpgaInit] error reading input: %w", err)
 				}
 			}
 		}
 
-		N8.FpgaInit(buf, nil)
-		os.Exit(0)
+		if err := N8.FpgaInit(buf, nil); err != nil {
+			return err
+		}
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // GetRtc returns the time currently set on the N8 RTC.
-func GetRtc(args []string) {
+func GetRtc(args []string) error {
 	fs := flag.NewFlagSet("getrtc", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
-		rtc := N8.GetRtc()
+		rtc, err := N8.GetRtc()
+		if err != nil {
+			return err
+		}
 		rtc.Print()
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // LoadRom loads ROM or OS.
 //
 // Auto detects normal ROM or `ROM_TYPE_OS`, optionally loads
 // provided mappe data, and starts the ROM. Prints MapConfig.
-func LoadRom(args []string) {
+func LoadRom(args []string) error {
 	fs := flag.NewFlagSet("loadrom", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
-	romPath := fs.String("rom", "", "path to rom")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
+	romPath := fs.String("rom", "", "path to rom (iNES, NES 2.0, UNIF or FDS)")
 	mapPath := fs.String("map", "", "path to copy from, prefix with `sd:` for file on the SD card")
+	verify := fs.Bool("verify", false, "(optional) check the CRC of the rom after writing it and retry on a mismatch")
+	dbPath := fs.String("db", "", "(optional) header database, NES 2.0 DB XML or JSON, to correct the rom header from")
 	fs.Parse(args)
 
-	if *device != "" && *romPath != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help && *romPath != "" {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
+		N8.Verify = *verify
 
 		rom, err := nesrom.NewNesRom(*romPath)
 		if err != nil {
-			log.Fatalf("[loadRom] rom error: %v", err) // TODO: add a better error here
+			return fmt.Errorf("[loadRom] rom error: %w", err) // TODO: add a better error here
+		}
+		var expected *n8.MapConfig // the config for a fixed header
+		if *dbPath != "" {
+			db, err := nesrom.LoadHeaderDB(*dbPath)
+			if err != nil {
+				return fmt.Errorf("[loadRom] %w", err)
+			}
+			fixes, found := rom.FixHeader(db)
+			rom.Print()
+			printDatabase(found, "Fixed", fixes)
+			if len(fixes) > 0 {
+				expected = n8.NewConfigFromNesRom(rom)
+			}
+		} else {
+			rom.Print()
 		}
-		rom.Print()
 
 		if rom.GetType() == nesrom.ROM_TYPE_OS {
-			N8.LoadOS(rom, *mapPath)
+			err = N8.LoadOS(rom, *mapPath)
 		} else {
-			N8.LoadGame(*romPath, *mapPath)
+			err = N8.LoadGame(rom, *mapPath)
+		}
+		if err != nil {
+			return err
+		}
+
+		config, err := N8.GetConfig()
+		if err != nil {
+			return err
+		}
+		config.Print()
+		if expected != nil && (config.GetMapIndex() != expected.MapIndex || config.GetSubmap() != expected.GetSubmap()) {
+			fmt.Printf("[loadRom] the N8 loaded mapper %d.%d, the fixed header gives %d.%d\n",
+				config.GetMapIndex(), config.GetSubmap(), expected.MapIndex, expected.GetSubmap())
+		}
+		return nil
+	}
+
+	fs.Usage()
+	return nil
+}
+
+// List lists directories on the SD card.
+//
+// Takes any number of `sd:` paths, the root directory if none are given.
+func List(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
+	long := fs.Bool("l", false, "(optional) long format, with attributes, size and modification time")
+	sortBy := fs.String("sort", "name", "(optional) sort by 'name', 'size', 'time' or 'none' (as listed by the N8)")
+	reverse := fs.Bool("r", false, "(optional) reverse the sort order")
+	recursive := fs.Bool("R", false, "(optional) list subdirectories recursively")
+	asJSON := fs.Bool("json", false, "(optional) print entries as a JSON array")
+	fs.Parse(args)
+
+	if !*help {
+		less, ok := listSorts[*sortBy]
+		if !ok {
+			return fmt.Errorf("[ls] unknown sort %q", *sortBy)
+		}
+
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
 		}
+		defer disconnect()
 
-		N8.GetConfig().Print()
-		os.Exit(0)
+		paths := fs.Args()
+		if len(paths) == 0 {
+			paths = []string{"sd:"}
+		}
+
+		l := &lister{long: *long, recursive: *recursive, json: *asJSON, less: less, reverse: *reverse}
+		for i, path := range paths {
+			if i > 0 && !l.json {
+				fmt.Println()
+			}
+			if err := l.list(strings.TrimPrefix(path, "sd:"), len(paths) > 1 || l.recursive); err != nil {
+				return err
+			}
+		}
+
+		if l.json {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(l.entries)
+		}
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // MakeDirectory creates a directory on the N8.
-func MakeDirectory(args []string) {
+func MakeDirectory(args []string) error {
 	fs := flag.NewFlagSet("mkdir", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	path := fs.String("path", "", "directory to create on the SD card")
 	fs.Parse(args)
 
-	if *device != "" && *path != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help && *path != "" {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
-		N8.MakeDir(*path)
+		if err := N8.MakeDir(*path); err != nil {
+			return err
+		}
 		fmt.Printf("[mkdir] \"%s\" created \n", *path)
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // ReadMemory reads data from memory address.
 //
 // Writes data to file if path specified, otherwise prints to standard output.
-func ReadMemory(args []string) {
+func ReadMemory(args []string) error {
 	fs := flag.NewFlagSet("readmemory", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	path := fs.String("path", "", "(optional) save data to a file (otherwise data is just printed to standard output)")
 	address := fs.Uint64("address", 0, "(required) hex address to read from (eg. '0xa000', '40960', etc)")
 	length := fs.Int64("length", 0, "(required) number of bytes to read (eg. '0x40', '64', etc)")
 	fs.Parse(args)
 
-	if *device != "" && *length != 0 {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help && *length != 0 {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
 		var buf []uint8 = make([]uint8, (uint32)(*length))
-		N8.ReadMemory((uint32)(*address), buf, (uint32)(len(buf)))
+		if err := N8.ReadMemory((uint32)(*address), buf, (uint32)(len(buf))); err != nil {
+			return err
+		}
 
 		if *path == "" {
 			fmt.Printf("[Read Memory]\n address $%04x-$%04x:\n", address, (uint32)(*address)+(uint32)(*length))
@@ -254,141 +524,454 @@ func ReadMemory(args []string) {
 		} else {
 			err := os.WriteFile(*path, buf, 0644)
 			if err != nil {
-				log.Fatalf("[readMemory] error writing to file %s: %v", *path, err)
+				return fmt.Errorf("[readMemory] error writing to file %s: %w", *path, err)
 			}
 		}
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // Reboot sends a reboot command to the N8.
-func Reboot(args []string) {
+func Reboot(args []string) error {
 	fs := flag.NewFlagSet("reboot", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
-		N8.Reboot()
+		if err := N8.Reboot(); err != nil {
+			return err
+		}
 		fmt.Println("[Reboot] N8 is rebooting")
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
+}
+
+// Remove deletes files and directories from the SD card.
+//
+// Takes any number of `sd:` paths, asks before deleting anything unless
+// `-y` is given.
+func Remove(args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
+	recursive := fs.Bool("r", false, "(optional) remove directories and their contents")
+	yes := fs.Bool("y", false, "(optional) do not ask for confirmation")
+	force := fs.Bool("force", false, "(optional) allow removing the EDN8 system folder")
+	dryRun := fs.Bool("dry-run", false, "(optional) only print what would be removed")
+	fs.Parse(args)
+
+	if !*help && fs.NArg() > 0 {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
+
+		var plan []string
+		for _, arg := range fs.Args() {
+			paths, err := removalPlan(strings.TrimPrefix(arg, "sd:"), *recursive, *force)
+			if err != nil {
+				return err
+			}
+			plan = append(plan, paths...)
+		}
+
+		if *dryRun {
+			for _, path := range plan {
+				fmt.Printf("[rm] would remove sd:%s\n", path)
+			}
+			return nil
+		}
+
+		if !*yes && !confirm(fmt.Sprintf("Remove %d entries from the SD card?", len(plan))) {
+			return fmt.Errorf("[rm] cancelled")
+		}
+
+		for _, path := range plan {
+			if err := N8.DeleteRecord(path); err != nil {
+				return err
+			}
+			fmt.Printf("[rm] removed sd:%s\n", path)
+		}
+		return nil
+	}
+
+	fs.Usage()
+	return nil
+}
+
+// RomInfo prints what goedlink reads from ROM files, without a device.
+//
+// Folders are searched for ROMs. A file that cannot be read is reported
+// and the rest are still printed.
+func RomInfo(args []string) error {
+	fs := flag.NewFlagSet("rominfo", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	asJSON := fs.Bool("json", false, "(optional) print ROMs as a JSON array")
+	asCSV := fs.Bool("csv", false, "(optional) print ROMs as CSV with a header row")
+	dbPath := fs.String("db", "", "(optional) header database, NES 2.0 DB XML or JSON, to check headers against without changing them")
+	fix := fs.Bool("fix", false, "(optional) rewrite ROMs whose header differs from the -db entry")
+	fs.Parse(args)
+
+	if !*help && fs.NArg() > 0 {
+		if *asJSON && *asCSV {
+			return fmt.Errorf("[rominfo] -json and -csv cannot be used together")
+		}
+		if *fix && *dbPath == "" {
+			return fmt.Errorf("[rominfo] -fix needs a header database, see -db")
+		}
+		var db nesrom.HeaderDB
+		if *dbPath != "" {
+			headers, err := nesrom.LoadHeaderDB(*dbPath)
+			if err != nil {
+				return fmt.Errorf("[rominfo] %w", err)
+			}
+			db = headers
+		}
+
+		paths, err := romPaths(fs.Args())
+		if err != nil {
+			return err
+		}
+
+		var infos []*romInfo
+		failed, printed := 0, 0
+		for _, path := range paths {
+			rom, err := nesrom.NewNesRom(path)
+			if err != nil {
+				failed++
+				infos = append(infos, &romInfo{Path: path, Error: err.Error(), Warnings: []string{}, Differences: []string{}})
+				if !*asJSON && !*asCSV {
+					fmt.Fprintf(os.Stderr, "[rominfo] %s: %s\n", path, err)
+				}
+				continue
+			}
+			var differences []string
+			found := false
+			written := ""
+			if db != nil {
+				differences, found = rom.CheckHeader(db)
+			}
+			var writeErr error
+			if *fix && len(differences) > 0 {
+				fixed, err := nesrom.NewNesRom(path)
+				if err == nil {
+					fixed.FixHeader(db)
+					written, err = writeFixedRom(path, fixed)
+				}
+				if err != nil {
+					failed++
+					writeErr = err
+				} else {
+					rom = fixed
+				}
+			}
+			config := n8.NewConfigFromNesRom(rom)
+			config.Serialize()
+			info := newRomInfo(path, rom, config)
+			info.InDatabase = found
+			info.Differences = append(info.Differences, differences...)
+			info.Fixed = written != ""
+			if writeErr != nil {
+				info.Error = writeErr.Error()
+			}
+			infos = append(infos, info)
+
+			if !*asJSON && !*asCSV {
+				if writeErr != nil {
+					fmt.Fprintf(os.Stderr, "[rominfo] %s: %s\n", path, writeErr)
+				}
+				if printed > 0 {
+					fmt.Println()
+				}
+				printed++
+				fmt.Printf("%s:\n", path)
+				rom.Print()
+				if db != nil {
+					label := "Differs"
+					if written != "" {
+						label = "Fixed"
+					}
+					printDatabase(found, label, differences)
+				}
+				if written != "" {
+					fmt.Printf("Written  : %s\n", written)
+				}
+				fmt.Println("Map Config:")
+				config.PrintFull()
+			}
+		}
+
+		switch {
+		case *asJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(infos); err != nil {
+				return err
+			}
+		case *asCSV:
+			w := csv.NewWriter(os.Stdout)
+			w.Write(romInfoColumns)
+			for _, info := range infos {
+				w.Write(info.record())
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return err
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("[rominfo] %d of %d ROMs failed", failed, len(paths))
+		}
+		return nil
+	}
+
+	fs.Usage()
+	return nil
+}
+
+// ResetLink resynchronizes the serial protocol with the N8.
+func ResetLink(args []string) error {
+	fs := flag.NewFlagSet("reset-link", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
+	fs.Parse(args)
+
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
+
+		if err := N8.Resync(); err != nil {
+			return err
+		}
+		fmt.Println("[Reset Link] N8 is responding")
+		return nil
+	}
+
+	fs.Usage()
+	return nil
 }
 
 // Recovery runs N8 recovery operation.
-func Recovery(args []string) {
+func Recovery(args []string) error {
 	fs := flag.NewFlagSet("recovery", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show"+fs.Name()+"command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show"+fs.Name()+"command help")
+	dev := addDeviceFlags(fs)
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
 		fmt.Println("[recovery] EDIO core recovery...")
-		N8.Recovery()
+		if err := N8.Recovery(); err != nil {
+			return err
+		}
 		fmt.Println("[recovery] ok")
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // ServiceMode switches the N8 to service mode.
-func ServiceMode(args []string) {
+func ServiceMode(args []string) error {
 	fs := flag.NewFlagSet("servicemode", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
 		fmt.Println("[Service Mode]")
-		N8.EnterServiceMode()
+		if err := N8.EnterServiceMode(); err != nil {
+			return err
+		}
 		fmt.Println("[Service Mode] ok")
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // SetRtc sets the N8 RTC.
 //
 // Defaults to current time unless user specifies a time.
-func SetRtc(args []string) {
+func SetRtc(args []string) error {
 	fs := flag.NewFlagSet("setrtc", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	userTime := fs.String("time", time.Now().Format("2006-01-02 15:04:05"), "(optional) time (format `YYYY-MM-DD HH:mm:SS`)")
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
-
+	if !*help {
 		t, err := time.Parse("2006-01-02 15:04:05", *userTime)
 		if err != nil {
-			log.Fatalf("[setrtc] error parsing time string %s: %v", *userTime, err)
+			return fmt.Errorf("[setrtc] error parsing time string %s: %w", *userTime, err)
+		}
+
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
+
+		if err := N8.SetRtc(t); err != nil {
+			return err
 		}
-		N8.SetRtc(t)
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
+}
+
+// Sync brings a folder on the SD card in line with a folder on the host.
+//
+// Prints the plan, then carries it out unless `-dry-run` is given. Asks
+// before deleting anything unless `-y` is given.
+func Sync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
+	host := fs.String("host", "", "(required) folder on the host")
+	sd := fs.String("sd", "", "(required) folder on the SD card, prefix with sd:")
+	mode := fs.String("mode", "push", "(optional) 'push' host to SD, 'pull' SD to host or 'mirror' to make the SD folder an exact copy of the host folder")
+	exclude := fs.String("exclude", ".git", "(optional) comma separated name patterns to ignore on both sides")
+	dryRun := fs.Bool("dry-run", false, "(optional) only print the plan")
+	yes := fs.Bool("y", false, "(optional) do not ask before deleting from the SD card")
+	verify := fs.Bool("verify", false, "(optional) check the CRC of each file after the transfer and retry on a mismatch")
+	fs.Parse(args)
+
+	if !*help && *host != "" && *sd != "" {
+		if !strings.HasPrefix(strings.ToLower(*sd), "sd:") {
+			return fmt.Errorf("[sync] -sd must start with sd:")
+		}
+
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
+		N8.Verify = *verify
+
+		var patterns []string
+		if *exclude != "" {
+			patterns = strings.Split(*exclude, ",")
+		}
+		plan, err := N8.PlanSync(*host, (*sd)[3:], n8.SyncMode(*mode), patterns)
+		if err != nil {
+			return err
+		}
+
+		deletes := 0
+		for _, action := range plan {
+			fmt.Printf("[sync] %s\n", action)
+			if action.Op == n8.SYNC_DELETE {
+				deletes++
+			}
+		}
+		if len(plan) == 0 {
+			fmt.Println("[sync] already in sync")
+			return nil
+		}
+		if *dryRun {
+			return nil
+		}
+
+		if deletes > 0 && !*yes && !confirm(fmt.Sprintf("Delete %d entries from the SD card?", deletes)) {
+			return fmt.Errorf("[sync] cancelled")
+		}
+		if err := N8.ApplySync(plan); err != nil {
+			return err
+		}
+		fmt.Printf("[sync] %d steps done\n", len(plan))
+		return nil
+	}
+
+	fs.Usage()
+	return nil
 }
 
 // WriteFlash writes data to N8 flash.
 //
 // Reads data from file and writes to flash.
-func WriteFlash(args []string) {
+func WriteFlash(args []string) error {
 	fs := flag.NewFlagSet("writeflash", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, '/dev/ttyACMO0')")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	path := fs.String("path", "", "(optional) read data from a file (otherwise data is read from standard input)")
 	address := fs.Uint64("address", 0, "(required) hex address to write to (eg. '0xa000', '40960', etc)")
 	fs.Parse(args)
 
-	if *device != "" {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
-
+	if !*help {
 		file, err := os.ReadFile(*path)
 		if err != nil {
-			log.Fatalf("[writeMemory] error reading file %s: %v", *path, err)
+			return fmt.Errorf("[writeFlash] error reading file %s: %w", *path, err)
+		}
+
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
 		}
+		defer disconnect()
 
-		N8.WriteFlash((uint32)(*address), file, (uint32)(len(file)))
-		os.Exit(0)
+		if err := N8.WriteFlash((uint32)(*address), file, (uint32)(len(file))); err != nil {
+			return err
+		}
+		return nil
 	}
 
 	fs.Usage()
+	return nil
 }
 
 // writeMemory writes data to N8 memory.
 //
 // Reads data from file if path specified, otherwise reads from standard input.
-func WriteMemory(args []string) {
+func WriteMemory(args []string) error {
 	fs := flag.NewFlagSet("writememory", flag.ExitOnError)
-	_ = fs.Bool("h", false, "show "+fs.Name()+" command help")
-	device := fs.String("d", "", "serial device path (eg, /dev/ttyACMO0)")
+	help := fs.Bool("h", false, "show "+fs.Name()+" command help")
+	dev := addDeviceFlags(fs)
 	path := fs.String("path", "", "(optional) read data from a file (otherwise data is read from standard input)")
 	address := fs.Uint64("address", 0, "(required) hex address to write to (eg. '0xa000', '40960', etc)")
 	length := fs.Int64("length", 0, "(required) number of bytes to read (eg. '0x40', '64', etc)")
 	fs.Parse(args)
 
-	if *device != "" && *length != 0 {
-		N8.InitSerial(*device, time.Second*2)
-		defer N8.Port.Close()
+	if !*help && *length != 0 {
+		disconnect, err := dev.connect()
+		if err != nil {
+			return err
+		}
+		defer disconnect()
 
 		var buf []uint8 = make([]uint8, *length)
 
@@ -401,24 +984,105 @@ func WriteMemory(args []string) {
 					if err == io.EOF {
 						break
 					}
-					fmt.Println("[writeMemory] error reading input:", err)
-					return
+					return fmt.Errorf("[writeMemory] error reading input: %w", err)
 				}
 			}
 		} else {
 			file, err := os.ReadFile(*path)
 			if err != nil {
-				log.Fatalf("[writeMemory] error reading file %s: %v", *path, err)
+				return fmt.Errorf("[writeMemory] error reading file %s: %w", *path, err)
 			}
 			copy(buf, file)
 		}
-		N8.WriteMemory((uint32)(*address), buf, (uint32)(len(buf)))
+		if err := N8.WriteMemory((uint32)(*address), buf, (uint32)(len(buf))); err != nil {
+			return err
+		}
 		// TODO: some sort of output with details of the write operation.
 
-		os.Exit(0)
+		return nil
 	}
 
 	fs.Usage()
+	return nil
+}
+
+// deviceFlags are the flags shared by every command that talks to the N8.
+type deviceFlags struct {
+	device  *string
+	trace   *string
+	replay  *string
+	timeout *time.Duration
+}
+
+// addDeviceFlags registers the shared device flags on fs.
+func addDeviceFlags(fs *flag.FlagSet) *deviceFlags {
+	return &deviceFlags{
+		device:  fs.String("d", "", "(optional) serial device path (eg, '/dev/ttyACMO0'), found automatically if not given"),
+		trace:   fs.String("trace", "", "(optional) record every transfer to a JSON lines trace file"),
+		replay:  fs.String("replay", "", "(optional) replay a trace file instead of using a serial device"),
+		timeout: fs.Duration("timeout", 0, "(optional) abort if a single operation takes longer than this (eg. '30s')"),
+	}
+}
+
+// connect opens the N8 described by the flags.
+//
+// Returns a function that closes the connection and trace file.
+func (f *deviceFlags) connect() (func(), error) {
+	N8.OpTimeout = *f.timeout // before InitSerial, which caps the read timeout to it
+
+	if *f.replay != "" {
+		file, err := os.Open(*f.replay)
+		if err != nil {
+			return nil, fmt.Errorf("[replay] %w", err)
+		}
+		defer file.Close()
+
+		port, err := n8.NewReplayTransport(file)
+		if err != nil {
+			return nil, err
+		}
+		N8.Address = *f.replay
+		N8.Port = port
+	} else {
+		device := *f.device
+		if device == "" {
+			var err error
+			device, err = n8.FindDevice()
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "[connect] using the N8 at %s\n", device)
+			N8.Rediscover = true
+		}
+		if err := N8.InitSerial(device, time.Second*2); err != nil {
+			return nil, err
+		}
+	}
+
+	N8.Progress = progress.update
+	N8.SetContext(ctx)
+
+	var traceFile *os.File
+	if *f.trace != "" {
+		var err error
+		traceFile, err = os.Create(*f.trace)
+		if err != nil {
+			N8.Port.Close()
+			return nil, fmt.Errorf("[trace] %w", err)
+		}
+		N8.Trace = n8.NewTracer(traceFile)
+	}
+
+	return func() {
+		progress.finish()
+		if err := N8.Cleanup(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		N8.Port.Close()
+		if traceFile != nil {
+			traceFile.Close()
+		}
+	}, nil
 }
 
 func main() {
@@ -432,8 +1096,19 @@ func main() {
 		helptext()
 		os.Exit(1)
 	}
-	cmd(os.Args[2:])
-
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := cmd(os.Args[2:])
+	progress.finish()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
 func helptext() {
@@ -451,18 +1126,26 @@ func helptext() {
 
 	fmt.Println(s)
 
-	AppMode(nil)
-	Copy(nil)
-	Info(nil)
-	InitFpga(nil)
-	GetRtc(nil)
-	LoadRom(nil)
-	MakeDirectory(nil)
-	ReadMemory(nil)
-	Reboot(nil)
-	Recovery(nil)
-	ServiceMode(nil)
-	SetRtc(nil)
-	WriteFlash(nil)
-	WriteMemory(nil)
+	help := []string{"-h"}
+	AppMode(help)
+	Copy(help)
+	Devices(help)
+	Emulate(help)
+	Info(help)
+	InitFpga(help)
+	GetRtc(help)
+	LoadRom(help)
+	List(help)
+	MakeDirectory(help)
+	ReadMemory(help)
+	Reboot(help)
+	Recovery(help)
+	Remove(help)
+	RomInfo(help)
+	ResetLink(help)
+	ServiceMode(help)
+	SetRtc(help)
+	Sync(help)
+	WriteFlash(help)
+	WriteMemory(help)
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// listEntry is a single SD card entry as printed by `ls`.
+type listEntry struct {
+	Path       string    `json:"path"`
+	Name       string    `json:"name"`
+	Size       uint32    `json:"size"`
+	ModTime    time.Time `json:"modTime"`
+	Attributes n8.Attr   `json:"attributes"`
+	Dir        bool      `json:"dir"`
+}
+
+// listSorts maps the `ls -sort` names to orderings, nil keeps the order
+// the N8 lists entries in.
+var listSorts = map[string]func(a, b *listEntry) bool{
+	"name": func(a, b *listEntry) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"size": func(a, b *listEntry) bool { return a.Size > b.Size },
+	"time": func(a, b *listEntry) bool { return a.ModTime.After(b.ModTime) },
+	"none": nil,
+}
+
+// lister prints SD card directories for `ls`.
+//
+// In JSON mode entries are collected in `entries` instead of printed.
+type lister struct {
+	long      bool
+	recursive bool
+	json      bool
+	less      func(a, b *listEntry) bool
+	reverse   bool
+	entries   []*listEntry
+}
+
+// list prints the directory at dir, or dir itself if it is a file.
+//
+// header prints the directory path above its entries.
+func (l *lister) list(dir string, header bool) error {
+	if strings.Trim(dir, "/") != "" {
+		info, err := N8.GetFileInfo(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			l.print([]*listEntry{newListEntry(path.Dir(dir), info)})
+			return nil
+		}
+	}
+
+	infos, err := N8.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]*listEntry, len(infos))
+	for i := range infos {
+		entries[i] = newListEntry(dir, &infos[i])
+	}
+	if l.less != nil {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if l.reverse {
+				return l.less(entries[j], entries[i])
+			}
+			return l.less(entries[i], entries[j])
+		})
+	}
+
+	if header && !l.json {
+		fmt.Printf("sd:/%s:\n", strings.Trim(dir, "/"))
+	}
+	l.print(entries)
+
+	if !l.recursive {
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.Dir {
+			continue
+		}
+		if !l.json {
+			fmt.Println()
+		}
+		if err := l.list(entry.Path, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *lister) print(entries []*listEntry) {
+	if l.json {
+		l.entries = append(l.entries, entries...)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.Dir {
+			name += "/"
+		}
+
+		if l.long {
+			fmt.Printf("%s %10d %s %s\n", entry.Attributes, entry.Size, entry.ModTime.Format("2006-01-02 15:04"), name)
+		} else {
+			fmt.Println(name)
+		}
+	}
+}
+
+func newListEntry(dir string, info *n8.FileInfo) *listEntry {
+	return &listEntry{
+		Path:       path.Join(dir, info.Name),
+		Name:       info.Name,
+		Size:       info.Size,
+		ModTime:    info.ModTime(),
+		Attributes: info.Attributes,
+		Dir:        info.IsDir(),
+	}
+}
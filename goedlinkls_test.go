@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeListDir writes files to games/ on the emulated SD card, each with
+// its own size and date, in an order that is not sorted by either.
+func writeListDir(t *testing.T) {
+	t.Helper()
+
+	device := useEmulator(t)
+	for _, file := range []struct {
+		path string
+		size int
+		year int
+	}{
+		{"games/b.nes", 3, 2021},
+		{"games/A.nes", 1, 2023},
+		{"games/sub/d.nes", 4, 2020},
+		{"games/c.nes", 2, 2022},
+	} {
+		// SetRtc has no reply, GetRtc waits for the device to have handled it
+		if err := N8.SetRtc(time.Date(file.year, 1, 1, 12, 0, 0, 0, time.Local)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := N8.GetRtc(); err != nil {
+			t.Fatal(err)
+		}
+		if err := device.WriteFile(file.path, make([]uint8, file.size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listNames returns the names of the entries l collected.
+func listNames(l *lister) []string {
+	var names []string
+	for _, entry := range l.entries {
+		names = append(names, entry.Name)
+	}
+
+	return names
+}
+
+func TestListSort(t *testing.T) {
+	writeListDir(t)
+
+	for _, test := range []struct {
+		sort    string
+		reverse bool
+		want    []string
+	}{
+		{"name", false, []string{"A.nes", "b.nes", "c.nes", "sub"}},
+		{"name", true, []string{"sub", "c.nes", "b.nes", "A.nes"}},
+		{"size", false, []string{"b.nes", "c.nes", "A.nes", "sub"}},
+		{"time", false, []string{"A.nes", "c.nes", "b.nes", "sub"}},
+		{"time", true, []string{"sub", "b.nes", "c.nes", "A.nes"}},
+		{"none", false, []string{"b.nes", "A.nes", "sub", "c.nes"}},
+		{"none", true, []string{"b.nes", "A.nes", "sub", "c.nes"}}, // nothing to reverse
+	} {
+		l := &lister{json: true, less: listSorts[test.sort], reverse: test.reverse}
+		if err := l.list("games", false); err != nil {
+			t.Fatal(err)
+		}
+		if got := listNames(l); !slices.Equal(got, test.want) {
+			t.Errorf("sort %s, reverse %t: %q, want %q", test.sort, test.reverse, got, test.want)
+		}
+	}
+}
+
+func TestListRecursive(t *testing.T) {
+	writeListDir(t)
+
+	out, err := captureStdout(t, func() error {
+		l := &lister{recursive: true, less: listSorts["name"]}
+		return l.list("/games/", true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "sd:/games:\nA.nes\nb.nes\nc.nes\nsub/\n\nsd:/games/sub:\nd.nes\n"
+	if out != want {
+		t.Errorf("ls -R output:\n%s\nwant:\n%s", out, want)
+	}
+
+	// a file is listed on its own
+	out, err = captureStdout(t, func() error {
+		l := &lister{long: true}
+		return l.list("games/c.nes", false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "          2 2022-01-01 12:00 c.nes\n"; !strings.HasSuffix(out, want) || strings.Count(out, "\n") != 1 {
+		t.Errorf("ls -l of a file = %q, want it to end with %q", out, want)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	writeListDir(t)
+
+	l := &lister{recursive: true, json: true, less: listSorts["name"]}
+	if err := l.list("games", true); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(l.entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []struct {
+		Path       string    `json:"path"`
+		Name       string    `json:"name"`
+		Size       uint32    `json:"size"`
+		ModTime    time.Time `json:"modTime"`
+		Attributes string    `json:"attributes"`
+		Dir        bool      `json:"dir"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	want := []string{"games/A.nes", "games/b.nes", "games/c.nes", "games/sub", "games/sub/d.nes"}
+	if !slices.Equal(paths, want) {
+		t.Fatalf("paths %q, want %q", paths, want)
+	}
+
+	sub, d := entries[3], entries[4]
+	if !sub.Dir || !strings.HasPrefix(sub.Attributes, "d") {
+		t.Errorf("sub %+v, want a directory", sub)
+	}
+	if d.Dir || d.Name != "d.nes" || d.Size != 4 || d.ModTime.Year() != 2020 {
+		t.Errorf("d.nes %+v", d)
+	}
+}
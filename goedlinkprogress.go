@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+const (
+	PROGRESS_MIN_SIZE      = 0x10000 // smaller transfers are not shown
+	PROGRESS_BAR_WIDTH     = 30
+	PROGRESS_TTY_INTERVAL  = time.Millisecond * 100
+	PROGRESS_LINE_INTERVAL = time.Second * 2
+)
+
+// progressBar renders N8 transfer progress.
+//
+// On a terminal a single bar is redrawn in place, otherwise a plain line
+// is printed every PROGRESS_LINE_INTERVAL so logs stay readable.
+type progressBar struct {
+	out  *os.File
+	tty  bool
+	last time.Time
+	open bool // a bar is drawn without its trailing newline
+}
+
+func newProgressBar(out *os.File) *progressBar {
+	p := &progressBar{out: out}
+	if info, err := out.Stat(); err == nil {
+		p.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	return p
+}
+
+// update is an `n8.ProgressFunc`.
+//
+// A Total of 0 means the size is not known, only the bytes done and the
+// rate are shown.
+func (p *progressBar) update(progress n8.Progress) {
+	unknown := progress.Total == 0
+	if !unknown && progress.Total < PROGRESS_MIN_SIZE {
+		return
+	}
+
+	if !p.tty && progress.Done == 0 {
+		p.last = time.Now()
+		return
+	}
+
+	finished := !unknown && progress.Done >= progress.Total
+	interval := PROGRESS_LINE_INTERVAL
+	if p.tty {
+		interval = PROGRESS_TTY_INTERVAL
+	}
+	if !finished && time.Since(p.last) < interval {
+		return
+	}
+	p.last = time.Now()
+
+	if unknown {
+		status := fmt.Sprintf("%s, %s/s", formatBytes((float64)(progress.Done)), formatBytes(progress.Rate()))
+		if p.tty {
+			fmt.Fprintf(p.out, "\r\033[K[%s] %s", progress.Op, status)
+			p.open = true
+		} else {
+			fmt.Fprintf(p.out, "[%s] %s\n", progress.Op, status)
+		}
+		return
+	}
+
+	percent := (float64)(progress.Done) / (float64)(progress.Total)
+	status := fmt.Sprintf("%s of %s, %s/s",
+		formatBytes((float64)(progress.Done)), formatBytes((float64)(progress.Total)), formatBytes(progress.Rate()))
+
+	if !p.tty {
+		fmt.Fprintf(p.out, "[%s] %3.0f%% %s\n", progress.Op, percent*100, status)
+		if finished {
+			p.last = time.Time{}
+		}
+		return
+	}
+
+	filled := (int)(percent * PROGRESS_BAR_WIDTH)
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", PROGRESS_BAR_WIDTH-filled)
+	fmt.Fprintf(p.out, "\r\033[K[%s] [%s] %3.0f%% %s", progress.Op, bar, percent*100, status)
+	p.open = true
+
+	if finished {
+		p.finish()
+	}
+}
+
+// finish ends a bar left open by an interrupted transfer.
+func (p *progressBar) finish() {
+	if p.open {
+		fmt.Fprintln(p.out)
+		p.open = false
+	}
+	p.last = time.Time{}
+}
+
+// formatBytes formats a byte count using binary units.
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
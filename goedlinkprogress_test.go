@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for _, test := range []struct {
+		n    float64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{0x180000, "1.5 MiB"},
+		{1 << 40, "1024.0 GiB"},
+	} {
+		if got := formatBytes(test.n); got != test.want {
+			t.Errorf("formatBytes(%.0f) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
+
+func TestProgressLines(t *testing.T) {
+	out, err := os.CreateTemp(t.TempDir(), "progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	// a file is not a terminal, so plain lines are printed
+	bar := newProgressBar(out)
+	report := func(op string, done uint64, total uint64) {
+		bar.update(n8.Progress{Op: op, Done: done, Total: total, Elapsed: time.Second})
+	}
+
+	report("ReadMemory", 0, 0x100)
+	report("ReadMemory", 0x100, 0x100) // too small to show
+	report("WriteMemory", 0, 0x40000)
+	report("WriteMemory", 0x10000, 0x40000) // within PROGRESS_LINE_INTERVAL
+	report("WriteMemory", 0x40000, 0x40000)
+	report("FileWrite", 0x20000, 0) // size not known
+	bar.finish()
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[WriteMemory] 100% 256.0 KiB of 256.0 KiB, 256.0 KiB/s\n" +
+		"[FileWrite] 128.0 KiB, 128.0 KiB/s\n"
+	if got := string(data); got != want {
+		t.Errorf("progress output:\n%s\nwant:\n%s", got, want)
+	}
+}
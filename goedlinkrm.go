@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// removalPlan returns the SD paths to delete to remove path, children
+// before their parent directory.
+//
+// Directories need recursive, anything in the EDN8 system folder needs
+// force.
+func removalPlan(path string, recursive bool, force bool) ([]string, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, fmt.Errorf("[rm] refusing to remove the SD card root")
+	}
+	if !force && strings.EqualFold(strings.SplitN(path, "/", 2)[0], n8.SYSTEM_DIR) {
+		return nil, fmt.Errorf("[rm] refusing to remove sd:%s from the EDN8 system folder without -force", path)
+	}
+
+	info, err := N8.GetFileInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	if !recursive {
+		return nil, fmt.Errorf("[rm] sd:%s is a directory, use -r to remove it", path)
+	}
+
+	// WalkDir visits parents before children, so the reverse order
+	// deletes every directory after its contents
+	plan := []string{path}
+	err = N8.WalkDir(path, func(entryPath string, info *n8.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		plan = append(plan, entryPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(plan)-1; i < j; i, j = i+1, j-1 {
+		plan[i], plan[j] = plan[j], plan[i]
+	}
+
+	return plan, nil
+}
+
+// confirm asks a yes or no question on standard input, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/emulator"
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// useEmulator points the global N8 at a new emulated device, shut down
+// when the test ends.
+func useEmulator(t *testing.T) *emulator.Device {
+	t.Helper()
+
+	device := emulator.New()
+	transport := emulator.NewTransport(device)
+	t.Cleanup(transport.Shutdown)
+	N8 = *n8.NewN8(transport)
+
+	return device
+}
+
+func TestRemovalPlan(t *testing.T) {
+	device := useEmulator(t)
+	for _, path := range []string{"games/a.nes", "games/sub/b.nes", "EDN8/MAPROUT.BIN"} {
+		if err := device.WriteFile(path, []uint8{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := removalPlan("/games/", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"games/sub/b.nes", "games/sub", "games/a.nes", "games"}
+	if !slices.Equal(plan, want) {
+		t.Errorf("removalPlan = %q, want %q", plan, want)
+	}
+
+	for _, path := range plan {
+		if err := N8.DeleteRecord(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := N8.GetFileInfo("games"); !n8.IsNotFound(err) {
+		t.Errorf("games still exists after removal: %v", err)
+	}
+
+	plan, err = removalPlan("EDN8/MAPROUT.BIN", false, true)
+	if err != nil || !slices.Equal(plan, []string{"EDN8/MAPROUT.BIN"}) {
+		t.Errorf("removalPlan with force = %q, %v", plan, err)
+	}
+}
+
+func TestRemovalPlanRefuses(t *testing.T) {
+	device := useEmulator(t)
+	if err := device.WriteFile("games/a.nes", []uint8{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path      string
+		recursive bool
+	}{
+		{"/", true},              // the root
+		{"edn8/MAPS", true},      // the system folder without force
+		{"games", false},         // a directory without recursive
+		{"games/missing", false}, // nothing there
+	} {
+		if plan, err := removalPlan(test.path, test.recursive, false); err == nil {
+			t.Errorf("removalPlan(%q) = %q, want an error", test.path, plan)
+		}
+	}
+}
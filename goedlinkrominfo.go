@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+	"forge.rights.ninja/jeff/goedlink/nesrom"
+)
+
+// romExtensions are the files `rominfo` reads from folders.
+var romExtensions = map[string]bool{".nes": true, ".fds": true, ".unf": true, ".unif": true}
+
+// romInfo is a single ROM as printed by `rominfo -json` and `-csv`.
+type romInfo struct {
+	Path         string   `json:"path"`
+	Error        string   `json:"error,omitempty"`
+	Format       string   `json:"format"`
+	Board        string   `json:"board,omitempty"`
+	Mapper       uint16   `json:"mapper"`
+	Submapper    uint8    `json:"submapper"`
+	PrgSize      uint32   `json:"prgSize"`
+	ChrSize      uint32   `json:"chrSize"`
+	SrmSize      uint32   `json:"srmSize"`
+	PrgRamSize   uint32   `json:"prgRamSize"`
+	PrgNvramSize uint32   `json:"prgNvramSize"`
+	ChrRamSize   uint32   `json:"chrRamSize"`
+	ChrNvramSize uint32   `json:"chrNvramSize"`
+	Mirroring    string   `json:"mirroring"`
+	Battery      bool     `json:"battery"`
+	Trainer      bool     `json:"trainer"`
+	Timing       string   `json:"timing"`
+	Console      string   `json:"console"`
+	RomID        string   `json:"romId"`
+	PrgChrCrc    string   `json:"prgChrCrc"`
+	MapConfig    string   `json:"mapConfig"`
+	Warnings     []string `json:"warnings"`
+	InDatabase   bool     `json:"inDatabase"`
+	Differences  []string `json:"differences"`
+	Fixed        bool     `json:"fixed"`
+}
+
+// romInfoColumns is the header row of `rominfo -csv`, in the order of
+// `romInfo.record`.
+var romInfoColumns = []string{
+	"path", "error", "format", "board", "mapper", "submapper", "prgSize", "chrSize", "srmSize",
+	"prgRamSize", "prgNvramSize", "chrRamSize", "chrNvramSize", "mirroring", "battery", "trainer",
+	"timing", "console", "romId", "prgChrCrc", "mapConfig", "warnings", "inDatabase", "differences", "fixed",
+}
+
+func newRomInfo(path string, rom *nesrom.NesRom, config *n8.MapConfig) *romInfo {
+	info := &romInfo{
+		Path:         path,
+		Format:       rom.GetFormat(),
+		Board:        rom.GetBoard(),
+		Mapper:       rom.GetMapper(),
+		Submapper:    rom.GetSubmapper(),
+		PrgSize:      rom.GetPrgSize(),
+		ChrSize:      rom.GetChrSize(),
+		SrmSize:      rom.GetSrmSize(),
+		PrgRamSize:   rom.GetPrgRamSize(),
+		PrgNvramSize: rom.GetPrgNvramSize(),
+		ChrRamSize:   rom.GetChrRamSize(),
+		ChrNvramSize: rom.GetChrNvramSize(),
+		Battery:      rom.IsBatteryRam(),
+		Trainer:      rom.GetTrainer() != nil,
+		Timing:       nesrom.TimingName(rom.GetTiming()),
+		Console:      nesrom.ConsoleName(rom.GetConsoleType()),
+		RomID:        fmt.Sprintf("%08X", rom.GetRomCrc()),
+		PrgChrCrc:    fmt.Sprintf("%08X", rom.GetPrgChrCrc()),
+		MapConfig:    hex.EncodeToString(config.GetSerialConfig()[n8.CONFIG_BASE:]),
+		Warnings:     rom.GetWarnings(),
+		Differences:  []string{},
+	}
+	if rom.GetMirroring() != 0 {
+		info.Mirroring = string(rune(rom.GetMirroring()))
+	}
+	if info.Warnings == nil {
+		info.Warnings = []string{}
+	}
+
+	return info
+}
+
+// record returns the ROM as a row for `rominfo -csv`.
+func (r *romInfo) record() []string {
+	return []string{
+		r.Path,
+		r.Error,
+		r.Format,
+		r.Board,
+		strconv.Itoa((int)(r.Mapper)),
+		strconv.Itoa((int)(r.Submapper)),
+		strconv.FormatUint((uint64)(r.PrgSize), 10),
+		strconv.FormatUint((uint64)(r.ChrSize), 10),
+		strconv.FormatUint((uint64)(r.SrmSize), 10),
+		strconv.FormatUint((uint64)(r.PrgRamSize), 10),
+		strconv.FormatUint((uint64)(r.PrgNvramSize), 10),
+		strconv.FormatUint((uint64)(r.ChrRamSize), 10),
+		strconv.FormatUint((uint64)(r.ChrNvramSize), 10),
+		r.Mirroring,
+		strconv.FormatBool(r.Battery),
+		strconv.FormatBool(r.Trainer),
+		r.Timing,
+		r.Console,
+		r.RomID,
+		r.PrgChrCrc,
+		r.MapConfig,
+		strings.Join(r.Warnings, "; "),
+		strconv.FormatBool(r.InDatabase),
+		strings.Join(r.Differences, "; "),
+		strconv.FormatBool(r.Fixed),
+	}
+}
+
+// romPaths expands folders in paths to the ROMs inside them, other paths
+// are kept as given.
+func romPaths(paths []string) ([]string, error) {
+	var roms []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			roms = append(roms, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, entry iofs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && romExtensions[strings.ToLower(filepath.Ext(file))] {
+				roms = append(roms, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("[rominfo] %w", err)
+		}
+	}
+
+	return roms, nil
+}
+
+// printDatabase prints, below `NesRom.Print`, how a ROM's header compares
+// with a header database. label names the differences, "Differs" or
+// "Fixed".
+func printDatabase(found bool, label string, differences []string) {
+	if !found {
+		fmt.Println("Database : not found")
+	}
+	for _, difference := range differences {
+		fmt.Printf("%-9s: %s\n", label, difference)
+	}
+}
+
+// writeFixedRom writes a ROM corrected by `rominfo -fix` over the file
+// at path, returning where it was written. UNIF ROMs are written beside
+// the original as .nes, which must not already exist.
+func writeFixedRom(path string, rom *nesrom.NesRom) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if rom.IsUnif() {
+		destination := filepath.Join(filepath.Dir(path), rom.GetName())
+		file, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return "", err
+		}
+		if _, err := file.Write(rom.GetRomData()); err != nil {
+			file.Close()
+			return "", err
+		}
+		return destination, file.Close()
+	}
+
+	// write a copy and rename it so a failed write leaves the ROM as it was
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, rom.GetRomData(), info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what f prints to standard output.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan []uint8)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	err = f()
+	w.Close()
+
+	return string(<-out), err
+}
+
+// writeRomDir writes a truncated ROM followed by two good ones, one of
+// them with a comma in its name and a warning, to a temporary folder.
+func writeRomDir(t *testing.T) (string, []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	header := []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	diskDude := []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0x00, 'D', 'i', 's', 'k', 'D', 'u', 'd', 'e', '!'}
+	files := []struct {
+		name string
+		data []uint8
+	}{
+		{"a.nes", append(slices.Clone(header), make([]uint8, 0x100)...)},
+		{"b.nes", append(slices.Clone(header), make([]uint8, 0x6000)...)},
+		{"c, d.nes", append(diskDude, make([]uint8, 0x6000)...)},
+		{"notes.txt", []uint8("not a ROM")},
+	}
+
+	var paths []string
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, file.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	return dir, paths[:3]
+}
+
+func TestRomInfo(t *testing.T) {
+	dir, paths := writeRomDir(t)
+
+	for _, test := range []struct {
+		name  string
+		args  []string
+		check func(t *testing.T, out string)
+	}{
+		{"text", nil, func(t *testing.T, out string) {
+			if strings.HasPrefix(out, "\n") || strings.Count(out, "\n\n") != 1 {
+				t.Errorf("want one blank line between the two ROMs printed:\n%s", out)
+			}
+			if strings.Contains(out, paths[0]) || !strings.Contains(out, paths[1]+":") || !strings.Contains(out, paths[2]+":") {
+				t.Errorf("want only the good ROMs printed:\n%s", out)
+			}
+		}},
+		{"json", []string{"-json"}, func(t *testing.T, out string) {
+			var infos []romInfo
+			if err := json.Unmarshal([]uint8(out), &infos); err != nil {
+				t.Fatal(err)
+			}
+			if len(infos) != 3 {
+				t.Fatalf("%d ROMs, want 3", len(infos))
+			}
+			if infos[0].Path != paths[0] || infos[0].Error == "" {
+				t.Errorf("first ROM %+v, want an error", infos[0])
+			}
+			if infos[1].Error != "" || infos[1].Format != "iNES" || infos[1].PrgSize != 0x4000 {
+				t.Errorf("second ROM %+v", infos[1])
+			}
+			if len(infos[2].Warnings) != 1 || !strings.Contains(infos[2].Warnings[0], `"DiskDude!"`) {
+				t.Errorf("third ROM warnings %q", infos[2].Warnings)
+			}
+		}},
+		{"csv", []string{"-csv"}, func(t *testing.T, out string) {
+			records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 4 || !slices.Equal(records[0], romInfoColumns) {
+				t.Fatalf("want a header row and 3 ROMs:\n%s", out)
+			}
+			for i, path := range paths {
+				if records[i+1][0] != path {
+					t.Errorf("row %d path %q, want %q", i+1, records[i+1][0], path)
+				}
+			}
+			if records[1][1] == "" || records[2][1] != "" {
+				t.Errorf("errors %q and %q, want only the first", records[1][1], records[2][1])
+			}
+			warnings := records[3][slices.Index(romInfoColumns, "warnings")]
+			if !strings.Contains(warnings, `"DiskDude!"`) {
+				t.Errorf("warnings %q", warnings)
+			}
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := captureStdout(t, func() error {
+				return RomInfo(append(test.args, dir))
+			})
+			if err == nil || !strings.Contains(err.Error(), "1 of 3 ROMs failed") {
+				t.Errorf("RomInfo = %v, want 1 of 3 ROMs failed", err)
+			}
+			test.check(t, out)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package n8_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/emulator"
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// newEmulatedN8 returns an N8 connected to a new emulated device, shut
+// down when the test ends.
+func newEmulatedN8(t testing.TB) (*n8.N8, *emulator.Device) {
+	t.Helper()
+
+	device := emulator.New()
+	transport := emulator.NewTransport(device)
+	t.Cleanup(transport.Shutdown)
+
+	return n8.NewN8(transport), device
+}
+
+// testData returns length bytes of a repeating, non-zero pattern.
+func testData(length int) []uint8 {
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = (uint8)(i*7 + i>>8 + 1)
+	}
+
+	return data
+}
+
+// writeTestRom writes an iNES ROM with prgBanks 16 KiB PRG banks and
+// chrBanks 8 KiB CHR banks to a temporary file and returns its path.
+func writeTestRom(t testing.TB, name string, mapper uint8, prgBanks int, chrBanks int, trainer []uint8) (string, []uint8) {
+	t.Helper()
+
+	header := []uint8{'N', 'E', 'S', 0x1A, (uint8)(prgBanks), (uint8)(chrBanks), mapper << 4, mapper & 0xF0}
+	header = append(header, make([]uint8, 8)...)
+	if trainer != nil {
+		header[6] |= 0x04
+	}
+
+	data := append(header, trainer...)
+	data = append(data, testData(prgBanks*0x4000+chrBanks*0x2000)...)
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, data
+}
@@ -1,10 +1,13 @@
 package n8
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -20,6 +23,10 @@ const CMD_HALT uint8 = 0x68        // 'h'
 const CMD_SELECT_GAME uint8 = 0x6E // 'n'
 const CMD_RUN_GAME uint8 = 0x73    // 's'
 
+const MEM_CHUNK_MIN uint32 = 0x20
+const MEM_CHUNK_START uint32 = 0x1000
+const MEM_CHUNK_MAX uint32 = 0x10000
+
 //
 // General Functions
 //
@@ -28,31 +35,35 @@ const CMD_RUN_GAME uint8 = 0x73    // 's'
 //
 // Converts the string to a byte slice, convers its length to a little-endian
 // byte slice, writes both to N8 FIFO.
-func (n8 *N8) SendString(str string) {
+func (n8 *N8) SendString(str string) error {
 	buf := []byte(str)
 	length := make([]uint8, 2)
 	binary.LittleEndian.PutUint16(length, (uint16)(len(buf)))
 
-	n8.FifoWr(length, 2)
-	n8.FifoWr(buf, (uint32)(len(buf)))
+	if err := n8.FifoWr(length, 2); err != nil {
+		return err
+	}
+	return n8.FifoWr(buf, (uint32)(len(buf)))
 }
 
 // GetConfig retrieves the configuration from the N8.
 //
 // Reads the configuration data from the device's memory and constructs a
 // MapConfig object from the binary data.
-func (n8 *N8) GetConfig() *MapConfig {
+func (n8 *N8) GetConfig() (*MapConfig, error) {
 	var cfg *MapConfig = NewMapConfig()
 
 	data := make([]uint8, len(cfg.GetSerialConfig()))
 
-	n8.ReadMemory(ADDR_CFG, data, (uint32)(len(cfg.GetSerialConfig())))
+	if err := n8.ReadMemory(ADDR_CFG, data, (uint32)(len(cfg.GetSerialConfig()))); err != nil {
+		return nil, err
+	}
 
-	return NewMapConfigFromBinary(data)
+	return NewMapConfigFromBinary(data), nil
 }
 
 // CopyFolder recursively copies a folder on the N8.
-func (n8 *N8) CopyFolder(source string, destination string) {
+func (n8 *N8) CopyFolder(source string, destination string) error {
 	if !strings.HasSuffix(source, "/") {
 		source += "/"
 	}
@@ -62,19 +73,115 @@ func (n8 *N8) CopyFolder(source string, destination string) {
 
 	dirs, err := getDirectories(source)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("[CopyFolder] %w", err)
 	}
 	for _, dir := range dirs {
-		n8.CopyFolder(dir, destination+filepath.Base(dir))
+		if err := n8.CopyFolder(dir, destination+filepath.Base(dir)); err != nil {
+			return err
+		}
 	}
 
 	files, err := getFiles(source)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("[CopyFolder] %w", err)
 	}
 	for _, file := range files {
-		n8.CopyFile(file, destination+filepath.Base(file))
+		if err := n8.CopyFile(file, destination+filepath.Base(file)); err != nil {
+			return err
+		}
 	}
+
+	return nil
+}
+
+// CopyFolderFromSD recursively copies a folder from the N8 SD card to
+// the host.
+//
+// Recreates the directory tree under destination, each file and folder
+// gets its FAT timestamp as its modification time.
+func (n8 *N8) CopyFolderFromSD(source string, destination string) error {
+	entries, err := n8.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return fmt.Errorf("[CopyFolderFromSD] %w", err)
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		entrySource := path.Join(source, entry.Name)
+		entryDestination := filepath.Join(destination, entry.Name)
+
+		if entry.IsDir() {
+			err = n8.CopyFolderFromSD(entrySource, entryDestination)
+			if err == nil && !entry.ModTime().IsZero() {
+				// set after the contents are written, which would update it
+				if err = os.Chtimes(entryDestination, entry.ModTime(), entry.ModTime()); err != nil {
+					err = fmt.Errorf("[CopyFolderFromSD] %w", err)
+				}
+			}
+		} else {
+			err = n8.copyFileFromSD(entrySource, entryDestination, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFileFromSD copies a single file from the SD card to the host,
+// setting its modification time from info.
+func (n8 *N8) copyFileFromSD(source string, destination string, info *FileInfo) error {
+	if n8.Resume {
+		return n8.resumeDownload(source, destination, info)
+	}
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("[CopyFile] error creating destination: %w", err)
+	}
+	if _, err := n8.readFileTo(source, 0, file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("[CopyFile] error writing to destination: %w", err)
+	}
+
+	return setHostTime(destination, info.ModTime())
+}
+
+// copyHostFile copies a file on the host.
+func copyHostFile(source *os.File, destination string) error {
+	file, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("[CopyFile] error creating destination: %w", err)
+	}
+	if _, err := io.Copy(file, source); err != nil {
+		file.Close()
+		return fmt.Errorf("[CopyFile] error writing to destination: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("[CopyFile] error writing to destination: %w", err)
+	}
+
+	return nil
+}
+
+// setHostTime sets the modification time of a file on the host, if
+// modTime is not zero.
+func setHostTime(destination string, modTime time.Time) error {
+	if modTime.IsZero() {
+		return nil
+	}
+	if err := os.Chtimes(destination, modTime, modTime); err != nil {
+		return fmt.Errorf("[CopyFile] error setting time on destination: %w", err)
+	}
+
+	return nil
 }
 
 // getFiles returns files in a directory as a []string.
@@ -114,87 +221,112 @@ func getDirectories(src string) ([]string, error) {
 // CopyFolder copies a file on the N8.
 //
 // Prefix the source or destination string with `sd:` to specify
-// a location on the N8 SD card.
-func (n8 *N8) CopyFile(source string, destination string) {
-	var sourceData []uint8
-	var err error
-
+// a location on the N8 SD card. Files are streamed in chunks, copies
+// within the SD card included.
+func (n8 *N8) CopyFile(source string, destination string) error {
 	source = strings.TrimSpace(source)
 	destination = strings.TrimSpace(destination)
 
 	if !strings.HasPrefix(strings.ToLower(source), "sd:") {
 		fileInfo, err := os.Stat(source)
 		if err == nil && fileInfo.IsDir() {
-			n8.CopyFolder(source, destination)
-			return
+			return n8.CopyFolder(source, destination)
 		}
 	}
 
 	if strings.HasSuffix(destination, "/") || strings.HasSuffix(destination, "\\") {
 		destination += filepath.Base(destination)
 	}
+	toSD := strings.HasPrefix(strings.ToLower(destination), "sd:")
 
 	if strings.HasPrefix(strings.ToLower(source), "sd:") {
 		source = source[3:]
-		sourceData = make([]uint8, n8.GetFileInfo(source).Size)
 
-		n8.OpenFile(source, FAT_READ)
-		n8.ReadFile(sourceData, (uint32)(len(sourceData)))
-		n8.CloseFile()
-	} else {
-		sourceData, err = os.ReadFile(source)
+		if strings.Trim(source, "/") == "" { // the root has no file info
+			if toSD {
+				return fmt.Errorf("[CopyFile] copying folders within the SD card is not supported")
+			}
+			return n8.CopyFolderFromSD(source, destination)
+		}
+
+		fileInfo, err := n8.GetFileInfo(source)
 		if err != nil {
-			log.Fatalln("[CopyFile] error reading source:", err)
+			return err
+		}
+
+		switch {
+		case fileInfo.IsDir() && toSD:
+			return fmt.Errorf("[CopyFile] copying folders within the SD card is not supported")
+		case fileInfo.IsDir():
+			return n8.CopyFolderFromSD(source, destination)
+		case toSD && sameSDPath(source, destination[3:]):
+			return fmt.Errorf("[CopyFile] sd:%s and sd:%s are the same file", source, destination[3:])
+		case toSD:
+			return n8.copyWithinSD(source, destination[3:], fileInfo.Size)
+		default:
+			return n8.copyFileFromSD(source, destination, fileInfo)
 		}
 	}
 
-	if strings.HasPrefix(strings.ToLower(destination), "sd:") {
-		destination = destination[3:]
+	file, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("[CopyFile] error reading source: %w", err)
+	}
+	defer file.Close()
 
-		n8.OpenFile(destination, FAT_CREATE_ALWAYS|FAT_WRITE)
-		n8.FileWrite(sourceData, (uint32)(len(sourceData)))
-		n8.CloseFile()
-	} else {
-		err = os.WriteFile(destination, sourceData, 0644)
-		if err != nil {
-			log.Fatalln("[CopyFile] error writing to destination:", err)
-		}
+	if toSD && n8.Resume {
+		return n8.resumeUpload(file, destination[3:])
+	}
+	if toSD {
+		_, err = n8.WriteFileFrom(destination[3:], file)
+		return err
 	}
+
+	return copyHostFile(file, destination)
 }
 
 // SetConfig sets the configuration on the N8.
 //
 // Writes the config binary to the N8's memory.
-func (n8 *N8) SetConfig(config *MapConfig) {
+func (n8 *N8) SetConfig(config *MapConfig) error {
 	buf := config.GetSerialConfig()
-	n8.WriteMemory(ADDR_CFG, buf, (uint32)(len(buf)))
+	return n8.WriteMemory(ADDR_CFG, buf, (uint32)(len(buf)))
 }
 
 // Command sends a command to the N8.
 //
 // Constructs a command buffer with a prefix and the command, and writes
 // it to the N8 FIFO.
-func (n8 *N8) Command(command uint8) {
+func (n8 *N8) Command(command uint8) error {
 	buf := make([]uint8, 2)
 
 	buf[0] = CMD_PREFIX
 	buf[1] = command
-	n8.FifoWr(buf, (uint32)(len(buf)))
+	return n8.FifoWr(buf, (uint32)(len(buf)))
 }
 
 // SelectGame selects a game on the N8.
 //
 // Returns the game index received from the device.
-func (n8 *N8) SelectGame(path string) uint16 {
-	n8.Command(CMD_SELECT_GAME)
-	n8.TxStringFifo(path)
+func (n8 *N8) SelectGame(path string) (uint16, error) {
+	if err := n8.Command(CMD_SELECT_GAME); err != nil {
+		return 0, err
+	}
+	if err := n8.TxStringFifo(path); err != nil {
+		return 0, err
+	}
 
-	resp := n8.Rx8()
+	resp, err := n8.Rx8()
+	if err != nil {
+		return 0, err
+	}
 	if resp != 0 {
-		log.Fatalf("[SelectGame] game select error: %v", resp)
+		return 0, fmt.Errorf("[SelectGame] game select error: %v", resp)
 	}
 
-	time.Sleep(time.Second * 2)
+	if err := n8.sleep(time.Second * 2); err != nil {
+		return 0, err
+	}
 
 	return n8.Rx16()
 }
@@ -203,13 +335,19 @@ func (n8 *N8) SelectGame(path string) uint16 {
 //
 // Reads the MAPROUT.BIN file to determine the mapper, and return a
 // path to the correct rbf.
-func getTestMapper(mapper uint16) string {
-	home := "./maps/"                        // TODO: read these from N8
-	maprout, _ := os.ReadFile("MAPROUT.BIN") // TODO: read from N8
+func getTestMapper(mapper uint16) (string, error) {
+	home := "./maps/"                          // TODO: read these from N8
+	maprout, err := os.ReadFile("MAPROUT.BIN") // TODO: read from N8
+	if err != nil {
+		return "", fmt.Errorf("[getTestMapper] %w", err)
+	}
+	if int(mapper) >= len(maprout) {
+		return "", fmt.Errorf("[getTestMapper] mapper %d is out of range", mapper)
+	}
 	pack := maprout[mapper]
 
 	if pack == 255 && mapper != 255 {
-		log.Fatalln("[getTestMapper] mapper is not supported")
+		return "", fmt.Errorf("[getTestMapper] mapper is not supported")
 	}
 
 	mapPath := home
@@ -221,43 +359,53 @@ func getTestMapper(mapper uint16) string {
 		mapPath += "0"
 	}
 
-	return mapPath + fmt.Sprintf("%d", pack) + ".RBF"
+	return mapPath + fmt.Sprintf("%d", pack) + ".RBF", nil
 }
 
 // MakeDir creates a directory on the N8.
 //
 // Trims whitespace from the path, ensures it starts with "sd:",
 // creates the directory on the device.
-func (n8 *N8) MakeDir(path string) {
+func (n8 *N8) MakeDir(path string) error {
 	path = strings.TrimSpace(path)
 
 	if !strings.HasPrefix(strings.ToLower(path), "sd:") {
-		log.Fatalln("[MakeDir] incorrect dir path: " + path + "\nValid paths must start with sd:")
+		return fmt.Errorf("[MakeDir] incorrect dir path: %s\nValid paths must start with sd:", path)
 	}
 
-	n8.mkdir(path[3:])
+	return n8.mkdir(path[3:])
 }
 
 // Halt halts the N8.
-func (n8 *N8) Halt() {
+func (n8 *N8) Halt() error {
 	config := NewMapConfig()
 
 	config.MapIndex = 0xff
-	n8.SetConfig(config)
-	n8.Command(CMD_HALT)
+	if err := n8.SetConfig(config); err != nil {
+		return err
+	}
+	if err := n8.Command(CMD_HALT); err != nil {
+		return err
+	}
 
-	if n8.Rx8() != 0 {
-		log.Fatalln("[Halt] Unexpected response to USB halt")
+	resp, err := n8.Rx8()
+	if err != nil {
+		return err
 	}
+	if resp != 0 {
+		return fmt.Errorf("[Halt] unexpected response to USB halt: %02x", resp)
+	}
+
+	return nil
 }
 
 // HaltExit sets the N8 configuration to exit halt mode.
-func (n8 *N8) HaltExit() {
+func (n8 *N8) HaltExit() error {
 	config := NewMapConfig()
 	config.MapIndex = 0xff
 	config.Ctrl = CTRL_UNLOCK
 
-	n8.SetConfig(config)
+	return n8.SetConfig(config)
 }
 
 // MapLoadSDC inits the FPGA with the correct mapper.
@@ -265,14 +413,20 @@ func (n8 *N8) HaltExit() {
 // Reads map data from `EDN8/MAPROUT.BIN` on N8 SD card,
 // then loads the FPGA with the correct `*.RBF` from within
 // `EDN8/MAPS/`.
-func (n8 *N8) MapLoadSDC(mapId uint8, config *MapConfig) {
+func (n8 *N8) MapLoadSDC(mapId uint16, config *MapConfig) error {
 	mapRout := make([]uint8, 4096)
 
 	mapPath := "EDN8/MAPS/"
 
-	n8.OpenFile("EDN8/MAPROUT.BIN", FAT_READ)
-	n8.ReadFile(mapRout, (uint32)(len(mapRout)))
-	n8.CloseFile()
+	if err := n8.OpenFile("EDN8/MAPROUT.BIN", FAT_READ); err != nil {
+		return err
+	}
+	if err := n8.ReadFile(mapRout, (uint32)(len(mapRout))); err != nil {
+		return err
+	}
+	if err := n8.CloseFile(); err != nil {
+		return err
+	}
 
 	mapPkg := mapRout[mapId]
 
@@ -280,8 +434,10 @@ func (n8 *N8) MapLoadSDC(mapId uint8, config *MapConfig) {
 		var config MapConfig
 		config.MapIndex = 255
 		config.Ctrl = CTRL_UNLOCK
-		n8.FpgaInitFromSD("EDN8/MAPS/255.RBF", &config)
-		log.Fatalf("[MapLoadSDC] unsupported mapper: %d", mapId)
+		if err := n8.FpgaInitFromSD("EDN8/MAPS/255.RBF", &config); err != nil {
+			return err
+		}
+		return fmt.Errorf("[MapLoadSDC] unsupported mapper: %d", mapId)
 	}
 
 	if mapPkg < 100 {
@@ -292,23 +448,30 @@ func (n8 *N8) MapLoadSDC(mapId uint8, config *MapConfig) {
 	}
 
 	mapPath += strconv.Itoa((int)(mapPkg)) + ".RBF"
-	n8.FpgaInitFromSD(mapPath, config)
+	return n8.FpgaInitFromSD(mapPath, config)
 }
 
 // Reboot sends a reboot command to the N8.
-func (n8 *N8) Reboot() {
-	n8.Command(CMD_REBOOT)
-	n8.Rx8()
+func (n8 *N8) Reboot() error {
+	if err := n8.Command(CMD_REBOOT); err != nil {
+		return err
+	}
+	if _, err := n8.Rx8(); err != nil {
+		return err
+	}
 
-	n8.ExitServiceMode()
+	return n8.ExitServiceMode()
 }
 
 // LoadOS loads an OS ROM.
 //
-// Initializes the FPGA with provided OS ROM.
-func (n8 *N8) LoadOS(rom *nesrom.NesRom, mapPath string) {
+// Initializes the FPGA with provided OS ROM. A trainer is written to
+// PRG RAM at `nesrom.ADDR_TRAINER`. Without a mapPath the map file is
+// taken from the local maps folder if there is one, or from `EDN8/MAPS`
+// on the SD card.
+func (n8 *N8) LoadOS(rom *nesrom.NesRom, mapPath string) error {
 	if mapPath == "" {
-		mapPath = getTestMapper(255)
+		mapPath, _ = getTestMapper(255) // empty without a local MAPROUT.BIN
 	}
 
 	var config MapConfig
@@ -316,46 +479,62 @@ func (n8 *N8) LoadOS(rom *nesrom.NesRom, mapPath string) {
 	config.Ctrl = CTRL_UNLOCK
 	config.Serialize()
 
-	n8.Command(CMD_REBOOT)
-	n8.TxCmdExec()
+	if err := n8.Command(CMD_REBOOT); err != nil {
+		return err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return err
+	}
 
-	n8.WriteMemory(rom.GetPrgAddr(), rom.GetPrgData(), rom.GetPrgSize())
-	n8.WriteMemory(rom.GetChrAddr(), rom.GetChrData(), rom.GetChrSize())
+	if err := n8.writeMemoryVerified(rom.GetPrgAddr(), rom.GetPrgData(), rom.GetPrgSize()); err != nil {
+		return err
+	}
+	if err := n8.writeMemoryVerified(rom.GetChrAddr(), rom.GetChrData(), rom.GetChrSize()); err != nil {
+		return err
+	}
+	if trainer := rom.GetTrainer(); trainer != nil {
+		if err := n8.writeMemoryVerified(nesrom.ADDR_TRAINER, trainer, (uint32)(len(trainer))); err != nil {
+			return err
+		}
+	}
 
-	n8.GetStatus()
+	if _, _, err := n8.GetStatus(); err != nil {
+		return err
+	}
 
 	if mapPath == "" {
-		n8.MapLoadSDC(255, &config)
-	} else {
-		file, err := os.ReadFile(mapPath)
-		if err != nil {
-			log.Fatalf("[LoadOS] error reading map file %s: %v", mapPath, err)
-		}
+		return n8.MapLoadSDC(255, &config)
+	}
 
-		n8.FpgaInit(file, &config)
+	file, err := os.ReadFile(mapPath)
+	if err != nil {
+		return fmt.Errorf("[LoadOS] error reading map file %s: %w", mapPath, err)
 	}
+
+	return n8.FpgaInit(file, &config)
 }
 
 // LoadGame loads a new game on the N8.
 //
 // Creates a `usb_games` directory for USB games and writes the ROM
 // and optional mapper `*.RBF` to it. It then selects the game and
-// runs it.
-func (n8 *N8) LoadGame(romPath string, mapPath string) {
+// runs it. UNIF ROMs are written as iNES, other ROMs are copied as
+// is, trainer included. The ROM is sent from the parsed image and the
+// map file is streamed from disk.
+func (n8 *N8) LoadGame(rom *nesrom.NesRom, mapPath string) error {
 	directory := "usb_games"
-	n8.MakeDir("sd:" + directory)
-
-	romDestinationPath := directory + "/" + filepath.Base(romPath)
-	fileData, err := os.ReadFile(romPath)
-	if err != nil {
-		log.Fatalln("[LoadGame] error reading source:", err)
+	if err := n8.MakeDir("sd:" + directory); err != nil {
+		return err
 	}
 
-	n8.OpenFile(romDestinationPath, FAT_CREATE_ALWAYS|FAT_WRITE)
-	n8.FileWrite(fileData, (uint32)(len(fileData)))
-	n8.CloseFile()
+	romDestinationPath := directory + "/" + rom.GetName()
+	if _, err := n8.WriteFileFrom(romDestinationPath, bytes.NewReader(rom.GetRomData())); err != nil {
+		return err
+	}
 
-	n8.SelectGame(romDestinationPath)
+	if _, err := n8.SelectGame(romDestinationPath); err != nil {
+		return err
+	}
 
 	// mapIndex := n8.SelectGame(romDestinationPath)
 	// if mapPath == "" {
@@ -365,15 +544,22 @@ func (n8 *N8) LoadGame(romPath string, mapPath string) {
 	rbfDestinationPath := changeExtension(romDestinationPath, "rbf")
 
 	if mapPath != "" {
-		fileData, _ = os.ReadFile(mapPath) // TODO: error checking
-		n8.OpenFile(rbfDestinationPath, FAT_WRITE|FAT_CREATE_ALWAYS)
-		n8.FileWrite(fileData, (uint32)(len(fileData)))
-		n8.CloseFile()
+		file, err := os.Open(mapPath)
+		if err != nil {
+			return fmt.Errorf("[LoadGame] error reading map file %s: %w", mapPath, err)
+		}
+		defer file.Close()
+
+		if _, err := n8.WriteFileFrom(rbfDestinationPath, file); err != nil {
+			return err
+		}
 	} else {
-		n8.DeleteRecord(rbfDestinationPath)
+		if err := n8.DeleteRecord(rbfDestinationPath); err != nil && !IsNotFound(err) {
+			return err
+		}
 	}
 
-	n8.Command(CMD_RUN_GAME)
+	return n8.Command(CMD_RUN_GAME)
 }
 
 //
@@ -381,31 +567,41 @@ func (n8 *N8) LoadGame(romPath string, mapPath string) {
 //
 
 // GetVdc retrieves the VDC state from the N8.
-func (n8 *N8) GetVdc() *Vdc {
+func (n8 *N8) GetVdc() (*Vdc, error) {
 	buf := make([]uint8, VDC_DATA_SIZE)
 
-	n8.TxCmd(CMD_GET_VDC)
-	n8.RxData(buf)
+	if err := n8.TxCmd(CMD_GET_VDC); err != nil {
+		return nil, err
+	}
+	if err := n8.RxData(buf); err != nil {
+		return nil, err
+	}
 
 	return NewVdc(buf)
 }
 
 // GetRtc retrieves the RTC (Real-Time Clock) time from the N8.
-func (n8 *N8) GetRtc() *RtcTime {
+func (n8 *N8) GetRtc() (*RtcTime, error) {
 	buf := make([]uint8, RTC_DATA_SIZE)
 
-	n8.TxCmd(CMD_RTC_GET)
-	n8.RxData(buf)
+	if err := n8.TxCmd(CMD_RTC_GET); err != nil {
+		return nil, err
+	}
+	if err := n8.RxData(buf); err != nil {
+		return nil, err
+	}
 
 	return NewRtcTimeFromSerial(buf)
 }
 
 // SetRtc sets the RTC (Real-Time Clock) time on the N8.
-func (n8 *N8) SetRtc(time time.Time) {
+func (n8 *N8) SetRtc(time time.Time) error {
 	rtcTime := NewRtcTime(time)
 
-	n8.TxCmd(CMD_RTC_SET)
-	n8.TxData(rtcTime.GetVals())
+	if err := n8.TxCmd(CMD_RTC_SET); err != nil {
+		return err
+	}
+	return n8.TxData(rtcTime.GetVals())
 }
 
 //
@@ -417,43 +613,72 @@ func (n8 *N8) SetRtc(time time.Time) {
 // Checks if the device is already in service mode. If not, it performs
 // a hard reset, waits for the device to boot, and verifies that it has
 // successfully entered service mode.
-func (n8 *N8) EnterServiceMode() {
-	if n8.isServiceMode() {
-		return
+func (n8 *N8) EnterServiceMode() error {
+	serviceMode, err := n8.isServiceMode()
+	if err != nil || serviceMode {
+		return err
 	}
 
-	n8.TxCmd(CMD_HARD_RESET)
-	n8.TxCmdExec()
-	n8.bootWait()
+	if err := n8.TxCmd(CMD_HARD_RESET); err != nil {
+		return err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return err
+	}
+	if err := n8.bootWait(); err != nil {
+		return err
+	}
 
-	if !n8.isServiceMode() {
-		log.Fatalln("[EnterServiceMode] device stuck in app mode")
+	serviceMode, err = n8.isServiceMode()
+	if err != nil {
+		return err
+	}
+	if !serviceMode {
+		return fmt.Errorf("[EnterServiceMode] device stuck in app mode")
 	}
+
+	return nil
 }
 
 // ExitServiceMode switches the N8 out of service mode.
 //
 // Checks if the device is currently in service mode. If so, it sends
 // the command to switch to app mode.
-func (n8 *N8) ExitServiceMode() {
-	if !n8.isServiceMode() {
-		return
+func (n8 *N8) ExitServiceMode() error {
+	serviceMode, err := n8.isServiceMode()
+	if err != nil || !serviceMode {
+		return err
 	}
 
-	n8.TxCmd(CMD_RUN_APP)
-	n8.bootWait()
+	if err := n8.TxCmd(CMD_RUN_APP); err != nil {
+		return err
+	}
+	if err := n8.bootWait(); err != nil {
+		return err
+	}
 
-	if n8.isServiceMode() {
-		log.Fatalln("[ExitServiceMode] device stuck in service mode")
+	serviceMode, err = n8.isServiceMode()
+	if err != nil {
+		return err
 	}
+	if serviceMode {
+		return fmt.Errorf("[ExitServiceMode] device stuck in service mode")
+	}
+
+	return nil
 }
 
 // isServiceMode checks if the n8serial device is in service mode.
-func (n8 *N8) isServiceMode() bool {
-	n8.TxCmd(CMD_GET_MODE)
-	resp := n8.Rx8()
+func (n8 *N8) isServiceMode() (bool, error) {
+	if err := n8.TxCmd(CMD_GET_MODE); err != nil {
+		return false, err
+	}
+	resp, err := n8.Rx8()
+	if err != nil {
+		return false, err
+	}
 
-	return resp == 0xA1
+	return resp == 0xA1, nil
 }
 
 // Recovery performs a recovery operation on the N8.
@@ -461,52 +686,97 @@ func (n8 *N8) isServiceMode() bool {
 // Checks if the device is in service mode, reads the current core CRC
 // from flash, initiates the USB recovery process, verifies the
 // recovery status.
-func (n8 *N8) Recovery() {
-	if !n8.isServiceMode() {
-		log.Fatalln("[Recovery] not in service mode")
+func (n8 *N8) Recovery() error {
+	serviceMode, err := n8.isServiceMode()
+	if err != nil {
+		return err
+	}
+	if !serviceMode {
+		return fmt.Errorf("[Recovery] not in service mode")
 	}
 
-	n8.Port.Close()
-	n8.InitSerial(n8.Address, time.Second*8)
+	if err := n8.ReopenSerial(time.Second * 8); err != nil {
+		return err
+	}
 
 	crc := make([]byte, 4)
-	n8.ReadFlash(ADDR_FLA_ICOR, crc, 4)
+	if err := n8.ReadFlash(ADDR_FLA_ICOR, crc, 4); err != nil {
+		return err
+	}
 
-	n8.TxCmd(CMD_USB_RECOV)
-	n8.Tx32(ADDR_FLA_ICOR)
-	n8.TxData(crc)
+	if err := n8.TxCmd(CMD_USB_RECOV); err != nil {
+		return err
+	}
+	if err := n8.Tx32(ADDR_FLA_ICOR); err != nil {
+		return err
+	}
+	if err := n8.TxData(crc); err != nil {
+		return err
+	}
 
-	ok, status := n8.GetStatus()
+	ok, status, err := n8.GetStatus()
+	if err != nil {
+		return err
+	}
 	if !ok {
-		log.Fatalf("[Recovery] status error: %v", status)
+		return fmt.Errorf("[Recovery] status error: %v", status)
 	}
 
-	n8.Port.Close()
-	n8.InitSerial(n8.Address, time.Second*2)
+	if err := n8.ReopenSerial(time.Second * 2); err != nil {
+		return err
+	}
 
 	if status == 0x0088 {
-		log.Fatalln("[Recovery] current core matches to recovery copy")
+		return fmt.Errorf("[Recovery] current core matches to recovery copy")
 	} else if status == 0x0000 {
-		log.Fatalf("[Recovery] recovery error %02x", status)
+		return &StatusError{Op: "Recovery", Cmd: CMD_USB_RECOV, Status: status}
 	}
+
+	return nil
 }
 
 // bootWait waits for the N8 to boot.
-func (n8 *N8) bootWait() {
+func (n8 *N8) bootWait() error {
+	// bootWait polls the status itself, resyncing would only slow it down
+	n8.resyncing = true
+	defer func() {
+		n8.resyncing = false
+	}()
+
 	for i := 0; i < 10; i++ {
 
 		n8.CloseSerial()
-		time.Sleep(time.Millisecond * 100)
-		n8.InitSerial(n8.Address, time.Second*2)
-		time.Sleep(time.Millisecond * 100)
+		if err := n8.sleep(time.Millisecond * 100); err != nil {
+			return err
+		}
+		if err := n8.ReopenSerial(time.Second * 2); err != nil {
+			if n8.Rediscover {
+				n8.rediscover()
+			}
+			continue
+		}
+		if err := n8.sleep(time.Millisecond * 100); err != nil {
+			return err
+		}
 
-		ok, _ := n8.GetStatus()
-		if ok {
-			return
+		ok, _, err := n8.GetStatus()
+		if err == nil && ok {
+			return nil
 		}
 	}
 
-	log.Fatalln("[BootWait] boot timeout")
+	return fmt.Errorf("[BootWait] boot timeout")
+}
+
+// rediscover switches to a newly found N8 serial device, in case the
+// device path changed while it rebooted.
+func (n8 *N8) rediscover() {
+	path, err := FindDevice()
+	if err != nil || path == n8.Address {
+		return
+	}
+
+	n8.InitSerial(path, time.Second*2) // failures are retried by bootWait
 }
 
 //
@@ -516,20 +786,30 @@ func (n8 *N8) bootWait() {
 // FifoWr writes data to the FIFO on the N8 device.
 //
 // Writes the provided data to the FIFO buffer at the specified address.
-func (n8 *N8) FifoWr(buf []uint8, length uint32) {
-	n8.WriteMemory(ADDR_FIFO, buf, length)
+func (n8 *N8) FifoWr(buf []uint8, length uint32) error {
+	return n8.WriteMemory(ADDR_FIFO, buf, length)
 }
 
 // MemoryCrc calculates the CRC of specific memory data on the N8.
 //
 // Sends a command to calculate the CRC of the specified memory region
 // and returns the CRC value received from the device.
-func (n8 *N8) MemoryCrc(addr uint32, length uint32) uint32 {
-	n8.TxCmd(CMD_MEM_CRC)
-	n8.Tx32(addr)
-	n8.Tx32(length)
-	n8.Tx32(CRC_INIT_VAL)
-	n8.TxCmdExec()
+func (n8 *N8) MemoryCrc(addr uint32, length uint32) (uint32, error) {
+	if err := n8.TxCmd(CMD_MEM_CRC); err != nil {
+		return 0, err
+	}
+	if err := n8.Tx32(addr); err != nil {
+		return 0, err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return 0, err
+	}
+	if err := n8.Tx32(CRC_INIT_VAL); err != nil {
+		return 0, err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return 0, err
+	}
 
 	return n8.Rx32()
 }
@@ -538,109 +818,197 @@ func (n8 *N8) MemoryCrc(addr uint32, length uint32) uint32 {
 //
 // Sends a command to set the specified byte value in the memory region
 // starting at the specified address.
-func (n8 *N8) MemorySet(addr uint32, val uint8, length uint32) {
-	n8.TxCmd(CMD_MEM_SET)
-	n8.Tx32(addr)
-	n8.Tx32(length)
-	n8.Tx8(val)
-	n8.TxCmdExec()
+func (n8 *N8) MemorySet(addr uint32, val uint8, length uint32) error {
+	if err := n8.TxCmd(CMD_MEM_SET); err != nil {
+		return err
+	}
+	if err := n8.Tx32(addr); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
+	if err := n8.Tx8(val); err != nil {
+		return err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return err
+	}
 
-	n8.IsStatusOkay()
+	return n8.checkStatus("MemorySet", CMD_MEM_SET)
 }
 
 // MemoryTest tests if a specific byte value exists in memory on the N8.
 //
 // Sends a command to test the memory region starting at the specified address,
 // returns 8-bit response.
-func (n8 *N8) MemoryTest(addr uint32, val uint8, length uint32) bool {
-	n8.TxCmd(CMD_MEM_TST)
-	n8.Tx32(addr)
-	n8.Tx32(length)
-	n8.Tx8(val)
-	n8.TxCmdExec()
+func (n8 *N8) MemoryTest(addr uint32, val uint8, length uint32) (bool, error) {
+	if err := n8.TxCmd(CMD_MEM_TST); err != nil {
+		return false, err
+	}
+	if err := n8.Tx32(addr); err != nil {
+		return false, err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return false, err
+	}
+	if err := n8.Tx8(val); err != nil {
+		return false, err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return false, err
+	}
+
+	resp, err := n8.Rx8()
+	if err != nil {
+		return false, err
+	}
 
-	return n8.Rx8() != 0
+	return resp != 0, nil
 }
 
 // ReadFlash reads data from flash memory on the N8.
 //
 // Sends a command to read data from flash memory, starting at the specified address,
 // into the provided uint8 slice.
-func (n8 *N8) ReadFlash(addr uint32, buf []uint8, length uint32) {
-	n8.TxCmd(CMD_FLA_RD)
-	n8.Tx32(addr)
-	n8.Tx32(length)
+func (n8 *N8) ReadFlash(addr uint32, buf []uint8, length uint32) error {
+	if err := n8.TxCmd(CMD_FLA_RD); err != nil {
+		return err
+	}
+	if err := n8.Tx32(addr); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
 
-	n8.RxData(buf)
+	return n8.RxData(buf)
 }
 
 // ReadMemory reads data from memory on the N8.
 //
-// Reads data from memory in chunks into the provided uint8 slice.
-func (n8 *N8) ReadMemory(addr uint32, buf []uint8, length uint32) {
+// Reads data from memory in chunks into the provided uint8 slice. Unless
+// `ChunkSize` is set, the chunk size doubles after each successful read
+// up to MEM_CHUNK_MAX and halves down to MEM_CHUNK_MIN when a read times
+// out, the timed out chunk is retried at the smaller size.
+func (n8 *N8) ReadMemory(addr uint32, buf []uint8, length uint32) error {
 	if length == 0 {
-		log.Fatalf("ReadMemory: No data")
-		return
+		return fmt.Errorf("[ReadMemory] no data")
 	}
 
-	// I've seen some issues when reading too much at a time, so I'm
-	// reading a max of 32 uint8s at a time.
-	const chunkSize uint32 = 0x20
-	for length > 0 {
-		currentChunk := chunkSize
-		if length < chunkSize {
-			currentChunk = length
+	chunkSize := n8.ChunkSize
+	if chunkSize == 0 {
+		if n8.memChunk == 0 {
+			n8.memChunk = MEM_CHUNK_START
 		}
-		tempBuf := make([]uint8, currentChunk)
+		chunkSize = n8.memChunk
+	}
 
-		n8.TxCmd(CMD_MEM_RD)
-		n8.Tx32(addr)
-		n8.Tx32(currentChunk)
-		n8.TxCmdExec()
+	progress := n8.startTransfer("ReadMemory", length)
+	for length > 0 {
+		currentChunk := min(chunkSize, length)
 
-		n8.RxData(tempBuf)
+		if err := n8.TxCmd(CMD_MEM_RD); err != nil {
+			return err
+		}
+		if err := n8.Tx32(addr); err != nil {
+			return err
+		}
+		if err := n8.Tx32(currentChunk); err != nil {
+			return err
+		}
+		if err := n8.TxCmdExec(); err != nil {
+			return err
+		}
 
-		copy(buf[:currentChunk], tempBuf)
-		buf = buf[currentChunk:]
+		err := n8.RxData(buf[:currentChunk])
+		if errors.Is(err, ErrTimeout) && n8.ChunkSize == 0 && chunkSize > MEM_CHUNK_MIN {
+			chunkSize = max(chunkSize/2, MEM_CHUNK_MIN)
+			n8.memChunk = chunkSize
+			continue
+		}
+		if err != nil {
+			return err
+		}
 
+		buf = buf[currentChunk:]
 		addr += currentChunk
 		length -= currentChunk
+		progress.add(currentChunk)
+
+		if n8.ChunkSize == 0 && currentChunk == chunkSize {
+			chunkSize = min(chunkSize*2, MEM_CHUNK_MAX)
+			n8.memChunk = chunkSize
+		}
 	}
+
+	return nil
 }
 
 // WriteFlash writes data to flash memory on the N8.
 //
 // Sends a command to write data to flash memory starting at the specified address,
 // verifies the status after operation.
-func (n8 *N8) WriteFlash(addr uint32, buf []uint8, length uint32) {
-	n8.TxCmd(CMD_FLA_WR)
-	n8.Tx32(addr)
-	n8.Tx32(length)
-
-	n8.TxDataACK(buf, length)
+func (n8 *N8) WriteFlash(addr uint32, buf []uint8, length uint32) error {
+	if err := n8.TxCmd(CMD_FLA_WR); err != nil {
+		return err
+	}
+	if err := n8.Tx32(addr); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
 
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[WriteFlash] status error: %v", resp)
+	if err := n8.txDataACK("WriteFlash", buf, length); err != nil {
+		return err
 	}
+
+	return n8.checkStatus("WriteFlash", CMD_FLA_WR)
 }
 
 // WriteMemory writes data to memory on the N8.
 //
 // Sends a command to write data to memory starting at the specified address,
-// writes the data from the provided uint8 slice.
-func (n8 *N8) WriteMemory(addr uint32, buf []uint8, length uint32) {
+// writes the data from the provided uint8 slice in chunks of up to
+// MEM_CHUNK_MAX bytes so progress can be reported.
+func (n8 *N8) WriteMemory(addr uint32, buf []uint8, length uint32) error {
 	if length == 0 {
-		log.Fatalf("WriteMemory: No data")
-		return
+		return fmt.Errorf("[WriteMemory] no data")
 	}
 
-	n8.TxCmd(CMD_MEM_WR)
-	n8.Tx32(addr)
-	n8.Tx32(length)
-	n8.TxCmdExec()
+	if err := n8.TxCmd(CMD_MEM_WR); err != nil {
+		return err
+	}
+	if err := n8.Tx32(addr); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return err
+	}
+
+	progress := n8.startTransfer("WriteMemory", length)
+	n8.pending = length
+	for length > 0 {
+		if err := n8.checkContext(); err != nil {
+			return err
+		}
+
+		currentChunk := min(MEM_CHUNK_MAX, length)
+		if err := n8.TxData(buf[:currentChunk]); err != nil {
+			return err
+		}
 
-	n8.TxData(buf)
+		buf = buf[currentChunk:]
+		length -= currentChunk
+		n8.pending = length
+		progress.add(currentChunk)
+	}
+
+	return nil
 }
 
 //
@@ -648,58 +1016,83 @@ func (n8 *N8) WriteMemory(addr uint32, buf []uint8, length uint32) {
 //
 
 // fpgaPostInit checks the N8 status after FPGA init and writes config to memory
-func (n8 *N8) fpgaPostInit(config *MapConfig) {
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[fpgaPostInit] status error: %v", resp)
+func (n8 *N8) fpgaPostInit(config *MapConfig, op string, cmd uint8) error {
+	if err := n8.checkStatus(op, cmd); err != nil {
+		return err
 	}
 
 	if config != nil {
-		n8.WriteMemory(ADDR_CFG, config.GetSerialConfig(), (uint32)(len(config.GetSerialConfig())))
+		return n8.WriteMemory(ADDR_CFG, config.GetSerialConfig(), (uint32)(len(config.GetSerialConfig())))
 	}
+
+	return nil
 }
 
 // FpgaInit initializes the N8 FPGA.
 //
 // Initlizes the FPGA with data from `[]uint8`,
 // along with initialization data.
-func (n8 *N8) FpgaInit(buf []uint8, config *MapConfig) {
-	n8.TxCmd(CMD_FPGA_USB)
-	n8.Tx32(uint32(len(buf)))
+func (n8 *N8) FpgaInit(buf []uint8, config *MapConfig) error {
+	if err := n8.TxCmd(CMD_FPGA_USB); err != nil {
+		return err
+	}
+	if err := n8.Tx32(uint32(len(buf))); err != nil {
+		return err
+	}
 
-	n8.TxDataACK(buf, uint32(len(buf)))
+	if err := n8.txDataACK("FpgaInit", buf, uint32(len(buf))); err != nil {
+		return err
+	}
 
-	n8.fpgaPostInit(config)
+	return n8.fpgaPostInit(config, "FpgaInit", CMD_FPGA_USB)
 }
 
 // FpgaInitFromFlash initializes the N8 FPGA.
 //
 // Initlizes the FPGA with data from at address in flash,
 // along with initialization data.
-func (n8 *N8) FpgaInitFromFlash(address uint32, config *MapConfig) {
-	n8.TxCmd(CMD_FPGA_FLA)
-	n8.Tx32(address)
-	n8.TxCmdExec()
+func (n8 *N8) FpgaInitFromFlash(address uint32, config *MapConfig) error {
+	if err := n8.TxCmd(CMD_FPGA_FLA); err != nil {
+		return err
+	}
+	if err := n8.Tx32(address); err != nil {
+		return err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return err
+	}
 
-	n8.fpgaPostInit(config)
+	return n8.fpgaPostInit(config, "FpgaInitFromFlash", CMD_FPGA_FLA)
 }
 
 // FpgaInitFromSD initializes the N8 FPGA using data from an SD card.
 //
 // Initlizes the FPGA with data on SD card, along with
 // initialization data.
-func (n8 *N8) FpgaInitFromSD(path string, config *MapConfig) {
-	fileinfo := n8.GetFileInfo(path)
+func (n8 *N8) FpgaInitFromSD(path string, config *MapConfig) error {
+	fileinfo, err := n8.GetFileInfo(path)
+	if err != nil {
+		return err
+	}
 
-	n8.OpenFile(path, FAT_READ)
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[FpgaInitFromSD] file open status error: %v", resp)
+	if err := n8.OpenFile(path, FAT_READ); err != nil {
+		return err
+	}
+	// CMD_FPGA_SDC reads the file itself, it is not closed afterwards
+	defer func() { n8.fileOpen = false }()
+	if err := n8.checkStatus("FpgaInitFromSD", CMD_FILE_OPEN); err != nil {
+		return err
 	}
 
-	n8.TxCmd(CMD_FPGA_SDC)
-	n8.Tx32(fileinfo.Size)
-	n8.TxCmdExec()
+	if err := n8.TxCmd(CMD_FPGA_SDC); err != nil {
+		return err
+	}
+	if err := n8.Tx32(fileinfo.Size); err != nil {
+		return err
+	}
+	if err := n8.TxCmdExec(); err != nil {
+		return err
+	}
 
-	n8.fpgaPostInit(config)
+	return n8.fpgaPostInit(config, "FpgaInitFromSD", CMD_FPGA_SDC)
 }
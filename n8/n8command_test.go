@@ -0,0 +1,157 @@
+package n8_test
+
+import (
+	"bytes"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/nesrom"
+)
+
+func TestMemory(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	// larger than MEM_CHUNK_START so reads are split into chunks
+	data := testData(0x30000)
+	if err := dev.WriteMemory(nesrom.ADDR_PRG, data, (uint32)(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]uint8, len(data))
+	if err := dev.ReadMemory(nesrom.ADDR_PRG, got, (uint32)(len(got))); err != nil {
+		t.Fatal(err)
+	}
+	// memory writes are not acknowledged, the read reply shows the
+	// device has caught up
+	if !bytes.Equal(device.Memory[nesrom.ADDR_PRG:nesrom.ADDR_PRG+(uint32)(len(data))], data) {
+		t.Fatal("WriteMemory: device memory does not match")
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("ReadMemory: data does not match")
+	}
+
+	crc, err := dev.MemoryCrc(nesrom.ADDR_PRG, (uint32)(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := crc32.ChecksumIEEE(data); crc != want {
+		t.Fatalf("MemoryCrc = %08X, want %08X", crc, want)
+	}
+}
+
+func TestLoadGame(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+	device.SetServiceMode(false) // games are selected by the menu
+
+	path, data := writeTestRom(t, "game.nes", 4, 2, 1, nil)
+	rom, err := nesrom.NewNesRom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.LoadGame(rom, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if game := device.Game(); game != "usb_games/game.nes" {
+		t.Errorf("selected game = %q, want usb_games/game.nes", game)
+	}
+	got, err := device.ReadFile("usb_games/game.nes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("ROM on the SD card does not match")
+	}
+}
+
+func TestLoadOS(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	trainer := bytes.Repeat([]uint8{0xEA}, (int)(nesrom.TRAINER_SIZE))
+	path, _ := writeTestRom(t, "os.nes", 255, 4, 2, trainer)
+	rom, err := nesrom.NewNesRom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapPath := filepath.Join(t.TempDir(), "255.RBF")
+	mapData := testData(0x1234)
+	if err := os.WriteFile(mapPath, mapData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.LoadOS(rom, mapPath); err != nil {
+		t.Fatal(err)
+	}
+
+	memory := func(addr uint32, length uint32) []uint8 {
+		return device.Memory[addr : addr+length]
+	}
+	if !bytes.Equal(memory(rom.GetPrgAddr(), rom.GetPrgSize()), rom.GetPrgData()) {
+		t.Error("PRG ROM was not written to memory")
+	}
+	if !bytes.Equal(memory(rom.GetChrAddr(), rom.GetChrSize()), rom.GetChrData()) {
+		t.Error("CHR ROM was not written to memory")
+	}
+	if !bytes.Equal(memory(nesrom.ADDR_TRAINER, nesrom.TRAINER_SIZE), trainer) {
+		t.Error("trainer was not written to memory")
+	}
+	if !bytes.Equal(device.FpgaData(), mapData) {
+		t.Error("FPGA was not loaded with the map file")
+	}
+}
+
+func TestLoadOSFromSD(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	path, _ := writeTestRom(t, "os.nes", 255, 2, 1, nil)
+	rom, err := nesrom.NewNesRom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a map file or a local maps folder, the OS map comes from
+	// the SD card
+	maprout := make([]uint8, 4096)
+	maprout[255] = 255
+	mapData := testData(0x1234)
+	if err := device.WriteFile("EDN8/MAPROUT.BIN", maprout); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.WriteFile("EDN8/MAPS/255.RBF", mapData); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.LoadOS(rom, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(device.FpgaData(), mapData) {
+		t.Error("FPGA was not loaded with the map file from the SD card")
+	}
+}
+
+func TestFpgaInitFromSD(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	mapData := testData(0x1234)
+	if err := device.WriteFile("EDN8/MAPS/255.RBF", mapData); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.FpgaInitFromSD("EDN8/MAPS/255.RBF", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(device.FpgaData(), mapData) {
+		t.Error("FPGA was not loaded with the map file")
+	}
+
+	// the map file is not left open
+	file, err := dev.Open("EDN8/MAPS/255.RBF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
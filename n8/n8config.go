@@ -27,7 +27,7 @@ const (
 
 type MapConfig struct {
 	serialConfig []uint8
-	MapIndex     uint8
+	MapIndex     uint16
 	PrgSize      uint32
 	ChrSize      uint32
 	SrmSize      uint32
@@ -50,8 +50,8 @@ func (c *MapConfig) GetSerialConfig() []uint8 {
 
 	return c.serialConfig
 }
-func (c *MapConfig) GetMapIndex() uint8 {
-	return (uint8)(c.GetSerialConfig()[CONFIG_BASE+0] | ((c.GetSerialConfig()[CONFIG_BASE+2] & 0xf0) << 4))
+func (c *MapConfig) GetMapIndex() uint16 {
+	return (uint16)(c.GetSerialConfig()[CONFIG_BASE+0]) | ((uint16)(c.GetSerialConfig()[CONFIG_BASE+2]&0xf0) << 4)
 }
 func (c *MapConfig) GetSubmap() uint8 {
 	return c.MapCfg >> 4
@@ -112,6 +112,7 @@ func NewMapConfig() *MapConfig {
 func NewConfigFromNesRom(rom *nesrom.NesRom) *MapConfig {
 	c := NewMapConfig()
 	c.MapIndex = rom.GetMapper()
+	c.MapCfg |= rom.GetSubmapper() << 4
 
 	switch rom.GetMirroring() {
 	case nesrom.MIR_HOR:
@@ -120,16 +121,24 @@ func NewConfigFromNesRom(rom *nesrom.NesRom) *MapConfig {
 		c.MapCfg |= CFG_MIR_V
 	case nesrom.MIR_4SC:
 		c.MapCfg |= CFG_MIR_4
-	}
-
-	if rom.GetChrSize() == 0 {
-		c.MapCfg |= CFG_CHR_RAM
+	case nesrom.MIR_1SC:
+		c.MapCfg |= CFG_MIR_1
 	}
 
 	c.PrgSize = rom.GetPrgSize()
 	c.ChrSize = rom.GetChrSize()
 	c.SrmSize = rom.GetSrmSize()
 
+	if rom.GetChrSize() == 0 {
+		c.MapCfg |= CFG_CHR_RAM
+		if rom.IsNes20() {
+			c.ChrSize = rom.GetChrRamSize() + rom.GetChrNvramSize()
+		}
+	}
+	if c.SrmSize == 0 {
+		c.MapCfg |= CFG_SRM_OFF
+	}
+
 	c.MasterVol = 8
 	c.SSKeyMenu = 0x14 // start + down
 	c.SSKeySave = 0xff // 0x14
@@ -140,7 +149,7 @@ func NewConfigFromNesRom(rom *nesrom.NesRom) *MapConfig {
 
 // Parse initializes MapConfig values from its own raw binary data.
 func (c *MapConfig) Parse() {
-	c.MapIndex = uint8(c.GetMapIndex())
+	c.MapIndex = c.GetMapIndex()
 	c.PrgSize = c.GetPrgSize()
 	c.ChrSize = c.GetChrSize()
 	c.SrmSize = c.GetSrmSize()
@@ -156,8 +165,8 @@ func (c *MapConfig) Parse() {
 func (c *MapConfig) Serialize() {
 	c.serialConfig = make([]uint8, CONFIG_BASE+16)
 
-	c.serialConfig[CONFIG_BASE+0] = c.MapIndex & 0xFF
-	c.serialConfig[CONFIG_BASE+2] = (c.MapIndex >> 4) & 0xF0
+	c.serialConfig[CONFIG_BASE+0] = (uint8)(c.MapIndex & 0xFF)
+	c.serialConfig[CONFIG_BASE+2] = (uint8)(c.MapIndex>>4) & 0xF0
 	c.serialConfig[CONFIG_BASE+1] = getMask(0x2000, c.PrgSize) & 0x0F
 	c.serialConfig[CONFIG_BASE+2] |= getMask(0x2000, c.ChrSize) & 0x0F
 	c.serialConfig[CONFIG_BASE+1] |= (getMask(0x0080, c.SrmSize) << 4)
@@ -172,7 +181,7 @@ func (c *MapConfig) Serialize() {
 // PrintFull prints all details about the MapConfig in human-readable format.
 func (config *MapConfig) PrintFull() {
 	fmt.Printf(" mapper.....%d sub.%d\n", config.GetMapIndex(), config.GetSubmap())
-	fmt.Printf(" prg size....%dK\n", config.GetChrSize()/1024)
+	fmt.Printf(" prg size....%dK\n", config.GetPrgSize()/1024)
 	chrType := ""
 	if config.GetMapCfg()&CFG_CHR_RAM != 0 {
 		chrType = "ram"
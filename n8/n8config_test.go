@@ -0,0 +1,55 @@
+package n8_test
+
+import (
+	"bytes"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+	"forge.rights.ninja/jeff/goedlink/nesrom"
+)
+
+func TestConfigFromFixedHeader(t *testing.T) {
+	for _, test := range []struct {
+		name               string
+		mapper             uint8
+		prgBanks, chrBanks int
+		header             nesrom.Header
+		want               []uint8
+	}{
+		{
+			// mapper above 255, submapper, CHR RAM and battery backed PRG RAM
+			name: "coolgirl", mapper: 1, prgBanks: 8, chrBanks: 0,
+			header: nesrom.Header{
+				Mapper: 342, Submapper: 2, Mirroring: nesrom.MIR_VER, Battery: true,
+				PrgNvramSize: 0x2000, ChrRamSize: 0x8000,
+			},
+			//         mapper  PRG/SRM  map/CHR  volume  cfg   keys              ctrl  menu key
+			want: []uint8{0x56, 0x64, 0x12, 0x08, 0x25, 0xFF, 0xFF, 0x00, 0x14, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			// no PRG RAM turns SRM off
+			name: "nrom", mapper: 3, prgBanks: 2, chrBanks: 1,
+			header: nesrom.Header{Mapper: 0, Mirroring: nesrom.MIR_HOR},
+			want:   []uint8{0x00, 0x02, 0x00, 0x08, 0x08, 0xFF, 0xFF, 0x00, 0x14, 0, 0, 0, 0, 0, 0, 0},
+		},
+	} {
+		path, _ := writeTestRom(t, test.name+".nes", test.mapper, test.prgBanks, test.chrBanks, nil)
+		rom, err := nesrom.NewNesRom(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, found := rom.FixHeader(nesrom.HeaderMap{rom.GetPrgChrCrc(): &test.header}); !found {
+			t.Fatalf("%s: not found in the database", test.name)
+		}
+
+		config := n8.NewConfigFromNesRom(rom)
+		config.Serialize()
+		if got := config.GetSerialConfig()[n8.CONFIG_BASE:]; !bytes.Equal(got, test.want) {
+			t.Errorf("%s: config % X, want % X", test.name, got, test.want)
+		}
+		if config.GetMapIndex() != test.header.Mapper || config.GetSubmap() != test.header.Submapper {
+			t.Errorf("%s: mapper %d.%d, want %d.%d", test.name,
+				config.GetMapIndex(), config.GetSubmap(), test.header.Mapper, test.header.Submapper)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package n8
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetContext binds ctx to the N8.
+//
+// Commands check ctx before they are sent and chunked transfers check it
+// between chunks, so cancelling ctx aborts the current operation with an
+// error wrapping `ctx.Err()`. Call `Cleanup` after an aborted operation
+// to leave the N8 in a usable state.
+func (n8 *N8) SetContext(ctx context.Context) {
+	n8.ctx = ctx
+}
+
+// Context returns the context bound with `SetContext`.
+func (n8 *N8) Context() context.Context {
+	if n8.ctx == nil {
+		return context.Background()
+	}
+
+	return n8.ctx
+}
+
+// checkContext returns an error if the bound context is done or the
+// current operation has run longer than `OpTimeout`.
+func (n8 *N8) checkContext() error {
+	if n8.ctx != nil {
+		if err := n8.ctx.Err(); err != nil {
+			n8.interrupted = true
+			return fmt.Errorf("[%s] aborted: %w", CommandName(n8.cmd), err)
+		}
+	}
+
+	if !n8.deadline.IsZero() && time.Now().After(n8.deadline) {
+		n8.interrupted = true
+		return fmt.Errorf("[%s] %w after %s", CommandName(n8.cmd), ErrOpTimeout, n8.OpTimeout)
+	}
+
+	return nil
+}
+
+// resetDeadline starts the `OpTimeout` clock again, it is called for
+// every command and every chunk of a transfer.
+func (n8 *N8) resetDeadline() {
+	if n8.OpTimeout > 0 {
+		n8.deadline = time.Now().Add(n8.OpTimeout)
+	} else {
+		n8.deadline = time.Time{}
+	}
+}
+
+// readTimeout caps a transport read timeout at `OpTimeout`, so a read
+// that stalls is given up within the operation timeout rather than
+// after the full read timeout.
+func (n8 *N8) readTimeout(timeout time.Duration) time.Duration {
+	if n8.OpTimeout > 0 && n8.OpTimeout < timeout {
+		return n8.OpTimeout
+	}
+
+	return timeout
+}
+
+// sleep waits for d, returning early if the bound context is done.
+func (n8 *N8) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-n8.Context().Done():
+		return n8.checkContext()
+	}
+}
@@ -0,0 +1,64 @@
+package n8_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/emulator"
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// silentTransport drops everything written to it, so the device never
+// replies.
+type silentTransport struct {
+	n8.Transport
+}
+
+func (t *silentTransport) Write(buf []uint8) (int, error) {
+	return len(buf), nil
+}
+
+func TestOpTimeoutShorterThanReadTimeout(t *testing.T) {
+	transport := emulator.NewTransport(emulator.New())
+	t.Cleanup(transport.Shutdown)
+
+	dev := n8.NewN8(&silentTransport{transport})
+	dev.OpTimeout = time.Millisecond * 100
+	if err := dev.ReopenSerial(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, _, err := dev.GetStatus()
+	if !errors.Is(err, n8.ErrOpTimeout) {
+		t.Fatalf("GetStatus = %v, want ErrOpTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetStatus gave up after %s, want about %s", elapsed, dev.OpTimeout)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	dev, _ := newEmulatedN8(t)
+	if err := dev.ReopenSerial(time.Millisecond * 200); err != nil { // Cleanup drains until a read times out
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dev.SetContext(ctx)
+	cancel()
+
+	if _, _, err := dev.GetStatus(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetStatus = %v, want context.Canceled", err)
+	}
+
+	dev.SetContext(context.Background())
+	if err := dev.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _, err := dev.GetStatus(); err != nil || !ok {
+		t.Fatalf("GetStatus after Cleanup = %v, %v", ok, err)
+	}
+}
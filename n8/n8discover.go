@@ -0,0 +1,82 @@
+package n8
+
+import (
+	"fmt"
+	"time"
+)
+
+// USB IDs the N8 Pro enumerates with.
+const (
+	USB_VENDOR_ID  string = "0483"
+	USB_PRODUCT_ID string = "5740"
+)
+
+const PROBE_TIMEOUT = time.Millisecond * 500
+
+// DeviceInfo describes a serial device found by `Discover`.
+//
+// `Found` is set when the device answered `CMD_STATUS` like an N8,
+// `ServiceMode` is only meaningful for found devices.
+type DeviceInfo struct {
+	Path        string
+	Found       bool
+	ServiceMode bool
+	Err         error
+}
+
+// Discover lists serial devices with the N8 Pro USB IDs and probes each
+// one to confirm it is an N8.
+func Discover() ([]DeviceInfo, error) {
+	paths, err := serialCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]DeviceInfo, 0, len(paths))
+	for _, path := range paths {
+		devices = append(devices, probe(path))
+	}
+
+	return devices, nil
+}
+
+// FindDevice returns the path of the first N8 found by `Discover`.
+func FindDevice() (string, error) {
+	devices, err := Discover()
+	if err != nil {
+		return "", err
+	}
+
+	for _, device := range devices {
+		if device.Found {
+			return device.Path, nil
+		}
+	}
+
+	return "", fmt.Errorf("[FindDevice] no N8 found, connect one or pass a device path")
+}
+
+// probe opens path and checks for a `0xA5xx` response to `CMD_STATUS`.
+func probe(path string) DeviceInfo {
+	info := DeviceInfo{Path: path}
+
+	var n8 N8
+	if err := n8.InitSerial(path, PROBE_TIMEOUT); err != nil {
+		info.Err = err
+		return info
+	}
+	defer n8.Port.Close()
+
+	// the device may not be an N8, a resync would only send it more
+	n8.resyncing = true
+
+	ok, _, err := n8.GetStatus()
+	if err != nil || !ok {
+		info.Err = err
+		return info
+	}
+	info.Found = true
+
+	info.ServiceMode, info.Err = n8.isServiceMode()
+	return info
+}
@@ -0,0 +1,52 @@
+package n8
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// serialCandidates returns the tty devices whose USB parent has the N8
+// Pro vendor and product IDs, found through sysfs.
+func serialCandidates() ([]string, error) {
+	entries, err := os.ReadDir("/sys/class/tty")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		device, err := filepath.EvalSymlinks(filepath.Join("/sys/class/tty", entry.Name(), "device"))
+		if err != nil {
+			continue // virtual terminals have no device
+		}
+
+		if usbIDsMatch(device) {
+			paths = append(paths, filepath.Join("/dev", entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// usbIDsMatch walks up from a sysfs device to the USB device it belongs
+// to and compares its IDs with the N8 Pro.
+func usbIDsMatch(dir string) bool {
+	for ; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		vendor, err := os.ReadFile(filepath.Join(dir, "idVendor"))
+		if err != nil {
+			continue
+		}
+		product, err := os.ReadFile(filepath.Join(dir, "idProduct"))
+		if err != nil {
+			return false
+		}
+
+		return strings.EqualFold(strings.TrimSpace(string(vendor)), USB_VENDOR_ID) &&
+			strings.EqualFold(strings.TrimSpace(string(product)), USB_PRODUCT_ID)
+	}
+
+	return false
+}
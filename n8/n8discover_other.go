@@ -0,0 +1,10 @@
+//go:build !linux
+
+package n8
+
+import "fmt"
+
+// serialCandidates is only implemented on Linux.
+func serialCandidates() ([]string, error) {
+	return nil, fmt.Errorf("[Discover] device discovery is only supported on Linux, pass a device path")
+}
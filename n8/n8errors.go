@@ -0,0 +1,49 @@
+package n8
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTimeout is returned when the N8 does not send the expected amount
+// of data before the transport read timeout.
+var ErrTimeout = errors.New("read timeout")
+
+// ErrVerify is returned when data written to or read from the N8 does
+// not match the CRC calculated by the N8.
+var ErrVerify = errors.New("verify failed")
+
+// ErrFileBusy is returned when opening a file on the SD card while
+// another is open, the N8 only has one open file at a time.
+var ErrFileBusy = errors.New("another file is open")
+
+// ErrOpTimeout is returned when an operation runs longer than
+// `N8.OpTimeout`.
+var ErrOpTimeout = fmt.Errorf("operation timeout: %w", context.DeadlineExceeded)
+
+// StatusError is returned when the N8 reports a non-zero status for a
+// command.
+//
+// `Op` names the library function that failed, `Cmd` is the command
+// byte that was sent and `Status` is the status code returned by the N8.
+type StatusError struct {
+	Op     string
+	Cmd    uint8
+	Status uint16
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("[%s] %s status error: 0x%02X", e.Op, CommandName(e.Cmd), e.Status)
+}
+
+// IsNotFound reports whether err is a `StatusError` caused by a file or
+// folder that does not exist on the SD card.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status == DELETE_FILE_NOT_FOUND || statusErr.Status == FAT_NO_PATH
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package n8
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Attr holds the FAT attribute bits of a file or directory.
+type Attr uint8
+
+const (
+	ATTR_RDO Attr = 0x01 // read-only
+	ATTR_HID Attr = 0x02 // hidden
+	ATTR_SYS Attr = 0x04 // system
+	ATTR_DIR Attr = 0x10 // directory
+	ATTR_ARC Attr = 0x20 // archive
+)
+
+// attrFlags are the attributes shown by `Attr.String`, in order.
+var attrFlags = []struct {
+	attr Attr
+	char byte
+}{{ATTR_DIR, 'd'}, {ATTR_RDO, 'r'}, {ATTR_HID, 'h'}, {ATTR_SYS, 's'}, {ATTR_ARC, 'a'}}
+
+// IsDir, ReadOnly, Hidden, System and Archive report whether each
+// attribute is set.
+func (a Attr) IsDir() bool    { return a&ATTR_DIR != 0 }
+func (a Attr) ReadOnly() bool { return a&ATTR_RDO != 0 }
+func (a Attr) Hidden() bool   { return a&ATTR_HID != 0 }
+func (a Attr) System() bool   { return a&ATTR_SYS != 0 }
+func (a Attr) Archive() bool  { return a&ATTR_ARC != 0 }
+
+// String formats the attributes like `ls`, eg. "d-h--" for a hidden
+// directory.
+func (a Attr) String() string {
+	s := make([]byte, len(attrFlags))
+	for i, flag := range attrFlags {
+		s[i] = '-'
+		if a&flag.attr != 0 {
+			s[i] = flag.char
+		}
+	}
+
+	return string(s)
+}
+
+// MarshalText encodes the attributes as their `String` form.
+func (a Attr) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// DecodeFatTime converts a FAT date and time to a `time.Time`.
+//
+// FAT timestamps have no time zone, the N8 RTC keeps local time so the
+// result is in `time.Local`. A zero date returns the zero `time.Time`.
+func DecodeFatTime(date uint16, clock uint16) time.Time {
+	if date == 0 {
+		return time.Time{}
+	}
+
+	return time.Date(
+		(int)(date>>9)+1980, (time.Month)((date>>5)&0x0F), (int)(date&0x1F),
+		(int)(clock>>11), (int)((clock>>5)&0x3F), (int)(clock&0x1F)*2,
+		0, time.Local)
+}
+
+// EncodeFatTime converts t to a FAT date and time in local time.
+//
+// FAT stores seconds in 2 second steps and years from 1980 to 2107,
+// times outside that range are clamped.
+func EncodeFatTime(t time.Time) (uint16, uint16) {
+	t = t.In(time.Local)
+	switch {
+	case t.Year() < 1980:
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.Local)
+	case t.Year() > 2107:
+		t = time.Date(2107, 12, 31, 23, 59, 58, 0, time.Local)
+	}
+
+	date := (uint16)(t.Year()-1980)<<9 | (uint16)(t.Month())<<5 | (uint16)(t.Day())
+	clock := (uint16)(t.Hour())<<11 | (uint16)(t.Minute())<<5 | (uint16)(t.Second()/2)
+
+	return date, clock
+}
+
+// ModTime returns the decoded modification time.
+func (fileInfo *FileInfo) ModTime() time.Time {
+	return DecodeFatTime(fileInfo.Date, fileInfo.Time)
+}
+
+// SetModTime sets `Date` and `Time` from t.
+func (fileInfo *FileInfo) SetModTime(t time.Time) {
+	fileInfo.Date, fileInfo.Time = EncodeFatTime(t)
+}
+
+// IsDir reports whether the entry is a directory.
+func (fileInfo *FileInfo) IsDir() bool {
+	return fileInfo.Attributes.IsDir()
+}
+
+// MarshalJSON encodes the entry with its decoded time and attributes.
+func (fileInfo FileInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name       string    `json:"name"`
+		Size       uint32    `json:"size"`
+		ModTime    time.Time `json:"modTime"`
+		Attributes Attr      `json:"attributes"`
+		Dir        bool      `json:"dir"`
+	}{fileInfo.Name, fileInfo.Size, fileInfo.ModTime(), fileInfo.Attributes, fileInfo.IsDir()})
+}
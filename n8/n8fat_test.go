@@ -0,0 +1,95 @@
+package n8_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestFatTime(t *testing.T) {
+	local := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.Local)
+	}
+
+	for _, test := range []struct {
+		name  string
+		in    time.Time
+		date  uint16
+		clock uint16
+		want  time.Time
+	}{
+		{"epoch", local(1980, 1, 1, 0, 0, 0), 0x0021, 0x0000, local(1980, 1, 1, 0, 0, 0)},
+		{"even second", local(2024, 3, 9, 13, 45, 30), 0x5869, 0x6DAF, local(2024, 3, 9, 13, 45, 30)},
+		{"odd second", local(2024, 3, 9, 13, 45, 31), 0x5869, 0x6DAF, local(2024, 3, 9, 13, 45, 30)},
+		{"last", local(2107, 12, 31, 23, 59, 58), 0xFF9F, 0xBF7D, local(2107, 12, 31, 23, 59, 58)},
+		{"before 1980", local(1970, 6, 1, 12, 0, 0), 0x0021, 0x0000, local(1980, 1, 1, 0, 0, 0)},
+		{"after 2107", local(2200, 1, 1, 0, 0, 0), 0xFF9F, 0xBF7D, local(2107, 12, 31, 23, 59, 58)},
+	} {
+		date, clock := n8.EncodeFatTime(test.in)
+		if date != test.date || clock != test.clock {
+			t.Errorf("%s: EncodeFatTime = %04X %04X, want %04X %04X", test.name, date, clock, test.date, test.clock)
+		}
+		if got := n8.DecodeFatTime(date, clock); !got.Equal(test.want) {
+			t.Errorf("%s: DecodeFatTime = %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	if got := n8.DecodeFatTime(0, 0x6DAF); !got.IsZero() {
+		t.Errorf("DecodeFatTime of a zero date = %s, want the zero time", got)
+	}
+}
+
+func TestAttr(t *testing.T) {
+	for _, test := range []struct {
+		attr n8.Attr
+		want string
+	}{
+		{0, "-----"},
+		{n8.ATTR_DIR, "d----"},
+		{n8.ATTR_DIR | n8.ATTR_HID, "d-h--"},
+		{n8.ATTR_RDO | n8.ATTR_SYS | n8.ATTR_ARC, "-r-sa"},
+		{n8.ATTR_DIR | n8.ATTR_RDO | n8.ATTR_HID | n8.ATTR_SYS | n8.ATTR_ARC, "drhsa"},
+	} {
+		if got := test.attr.String(); got != test.want {
+			t.Errorf("Attr(%02X).String() = %q, want %q", (uint8)(test.attr), got, test.want)
+		}
+		if text, err := test.attr.MarshalText(); err != nil || string(text) != test.want {
+			t.Errorf("Attr(%02X).MarshalText() = %q, %v, want %q", (uint8)(test.attr), text, err, test.want)
+		}
+	}
+}
+
+func TestFileInfoJSON(t *testing.T) {
+	modTime := time.Date(2024, 3, 9, 13, 45, 30, 0, time.Local)
+	for _, test := range []struct {
+		info n8.FileInfo
+		dir  bool
+		attr string
+	}{
+		{n8.FileInfo{Name: "GAME.NES", Size: 40976, Attributes: n8.ATTR_ARC}, false, "----a"},
+		{n8.FileInfo{Name: "EDN8", Attributes: n8.ATTR_DIR | n8.ATTR_HID}, true, "d-h--"},
+	} {
+		test.info.SetModTime(modTime)
+		data, err := json.Marshal(test.info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got struct {
+			Name       string    `json:"name"`
+			Size       uint32    `json:"size"`
+			ModTime    time.Time `json:"modTime"`
+			Attributes string    `json:"attributes"`
+			Dir        bool      `json:"dir"`
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != test.info.Name || got.Size != test.info.Size || !got.ModTime.Equal(modTime) ||
+			got.Attributes != test.attr || got.Dir != test.dir {
+			t.Errorf("%s: JSON %s", test.info.Name, data)
+		}
+	}
+}
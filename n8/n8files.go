@@ -1,8 +1,9 @@
 package n8
 
 import (
-	"fmt"
-	"log"
+	"errors"
+	"io/fs"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -20,11 +21,30 @@ const (
 
 const DELETE_FILE_NOT_FOUND uint16 = 0x04
 
+// FatFs result codes the N8 reports, besides DELETE_FILE_NOT_FOUND for
+// a missing file.
+const (
+	FAT_NO_PATH uint16 = 0x05 // a folder in the path does not exist
+	FAT_EXIST   uint16 = 0x08 // the file or folder already exists
+)
+
+// FILE_BLOCK_SIZE is the block size of file reads, the N8 sends a status
+// byte before each block. The block size is set by the firmware and the
+// whole read is requested with one command, so unlike memory reads (see
+// MEM_CHUNK_START) there is no chunk size to adapt.
+const FILE_BLOCK_SIZE uint32 = 0x1000
+
+// SECTOR_SIZE is the SD card sector size, `DiskRead` receives sectors
+// the same way file reads receive blocks.
+const SECTOR_SIZE uint32 = 512
+
+const DIR_BATCH_SIZE uint16 = 64
+
 type FileInfo struct {
 	Size       uint32
 	Date       uint16
 	Time       uint16
-	Attributes uint8
+	Attributes Attr
 	Name       string
 }
 
@@ -36,27 +56,23 @@ type FileInfo struct {
 //
 // Sends a file info command to the device with the specified path,
 // retrieves data and returns pointer to `FileInfo`.
-func (n8 *N8) GetFileInfo(path string) *FileInfo {
-
-	n8.TxCmd(CMD_FILE_INFO)
-	n8.TxString(path)
+func (n8 *N8) GetFileInfo(path string) (*FileInfo, error) {
+	if err := n8.TxCmd(CMD_FILE_INFO); err != nil {
+		return nil, err
+	}
+	if err := n8.TxString(path); err != nil {
+		return nil, err
+	}
 
-	resp := n8.Rx8()
-	if resp != 0 {
-		log.Fatalf("[GetFileInfo] file access error: %02x", resp)
+	if err := n8.rxResp("GetFileInfo", CMD_FILE_INFO); err != nil {
+		return nil, err
 	}
 
-	size, date, time, attributes, name := n8.RxFileInfo()
-	return &FileInfo{
-		Size:       size,
-		Date:       date,
-		Time:       time,
-		Attributes: attributes,
-		Name:       name}
+	return n8.RxFileInfo()
 }
 
 // SetFileInfo sets the attributes of the FileInfo struct.
-func (fileInfo *FileInfo) SetFileInfo(size uint32, date uint16, time uint16, attributes uint8, name string) {
+func (fileInfo *FileInfo) SetFileInfo(size uint32, date uint16, time uint16, attributes Attr, name string) {
 	fileInfo.Size = size
 	fileInfo.Date = date
 	fileInfo.Time = time
@@ -67,24 +83,23 @@ func (fileInfo *FileInfo) SetFileInfo(size uint32, date uint16, time uint16, att
 // DirRead reads the file info from the currently open directory on the N8.
 //
 // Sends a command to read file info from the currently open directory.
-func (n8 *N8) DirRead(maxNameLength uint16) *FileInfo {
+func (n8 *N8) DirRead(maxNameLength uint16) (*FileInfo, error) {
 	if maxNameLength == 0 {
 		maxNameLength = 0xffff
 	}
 
-	n8.TxCmd(CMD_FILE_DIR_READ)
-	n8.Tx16(maxNameLength)
-
-	resp := n8.Rx8()
-	if resp != 0 {
-		log.Fatalf("[DirRead] dir read error: %v", resp)
+	if err := n8.TxCmd(CMD_FILE_DIR_READ); err != nil {
+		return nil, err
+	}
+	if err := n8.Tx16(maxNameLength); err != nil {
+		return nil, err
 	}
 
-	var fileInfo FileInfo
-	size, date, time, attributes, name := n8.RxFileInfo()
-	fileInfo.SetFileInfo(size, date, time, attributes, name)
+	if err := n8.rxResp("DirRead", CMD_FILE_DIR_READ); err != nil {
+		return nil, err
+	}
 
-	return &fileInfo
+	return n8.RxFileInfo()
 }
 
 // GetDirRecords retrieves specified number of FileInfo entries from the
@@ -92,44 +107,56 @@ func (n8 *N8) DirRead(maxNameLength uint16) *FileInfo {
 //
 // Sends a command to retrieve a specified number of file information entries
 // from the currently open directory on the N8 device.
-func (n8 *N8) GetDirRecords(startIndex uint16, amount uint16, maxNameLength uint16) []FileInfo {
+func (n8 *N8) GetDirRecords(startIndex uint16, amount uint16, maxNameLength uint16) ([]FileInfo, error) {
 	fileInformation := make([]FileInfo, amount)
 
-	n8.TxCmd(CMD_FILE_DIR_GET)
-	n8.Tx16(startIndex)
-	n8.Tx16(amount)
-	n8.Tx16(maxNameLength)
+	if err := n8.TxCmd(CMD_FILE_DIR_GET); err != nil {
+		return nil, err
+	}
+	if err := n8.Tx16(startIndex); err != nil {
+		return nil, err
+	}
+	if err := n8.Tx16(amount); err != nil {
+		return nil, err
+	}
+	if err := n8.Tx16(maxNameLength); err != nil {
+		return nil, err
+	}
 
 	var i uint16
 	for i = 0; i < amount; i++ {
-		resp := n8.Rx8()
-		if resp != 0 {
-			log.Fatalf("[GetDirRecords] dir read error: %v", resp)
+		if err := n8.rxResp("GetDirRecords", CMD_FILE_DIR_GET); err != nil {
+			return nil, err
 		}
 
-		var fileInfo FileInfo
-		size, date, time, attributes, name := n8.RxFileInfo()
-		fileInfo.SetFileInfo(size, date, time, attributes, name)
+		fileInfo, err := n8.RxFileInfo()
+		if err != nil {
+			return nil, err
+		}
 
-		fileInformation[i] = fileInfo
+		fileInformation[i] = *fileInfo
 	}
 
-	return fileInformation
+	return fileInformation, nil
 }
 
 // FileCrc calculates the CRC of a file on the N8.
 //
 // Sends a file CRC command to the device with the specified length,
 // calculates the CRC value.
-func (n8 *N8) FileCrc(length uint32) uint32 {
-	n8.TxCmd(CMD_FILE_CRC)
-	n8.Tx32(length)
-	n8.Tx32(CRC_INIT_VAL)
-
-	resp := n8.Rx8()
+func (n8 *N8) FileCrc(length uint32) (uint32, error) {
+	if err := n8.TxCmd(CMD_FILE_CRC); err != nil {
+		return 0, err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return 0, err
+	}
+	if err := n8.Tx32(CRC_INIT_VAL); err != nil {
+		return 0, err
+	}
 
-	if resp != 0 {
-		log.Fatalf("[FileCrc] disk read error: %v", resp)
+	if err := n8.rxResp("FileCrc", CMD_FILE_CRC); err != nil {
+		return 0, err
 	}
 
 	return n8.Rx32()
@@ -142,111 +169,213 @@ func (n8 *N8) FileCrc(length uint32) uint32 {
 // OpenFile opens a file on the N8.
 //
 // Sends a file open command to the device with the specified path and access mode.
-func (n8 *N8) OpenFile(path string, mode uint8) {
-	n8.TxCmd(CMD_FILE_OPEN)
-	n8.Tx8(mode)
-	n8.TxString(path)
+func (n8 *N8) OpenFile(path string, mode uint8) error {
+	if err := n8.TxCmd(CMD_FILE_OPEN); err != nil {
+		return err
+	}
+	if err := n8.Tx8(mode); err != nil {
+		return err
+	}
+
+	n8.fileOpen = true
+	return n8.TxString(path)
 }
 
 // CloseFile closes the currently open file on the N8.
 //
 // Sends a file close command to the device to close the currently open file.
-func (n8 *N8) CloseFile() {
-	n8.TxCmd(CMD_FILE_CLOSE)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[CloseFile] response code error: %v", resp)
+func (n8 *N8) CloseFile() error {
+	n8.fileOpen = false
+	if err := n8.TxCmd(CMD_FILE_CLOSE); err != nil {
+		return err
 	}
+
+	return n8.checkStatus("CloseFile", CMD_FILE_CLOSE)
 }
 
 // FileSetPointer sets the file pointer to a specified address.
 //
 // Sends command to the N8 to set the file pointer to the specified address.
-func (n8 *N8) FileSetPointer(address uint32) {
-	n8.TxCmd(CMD_FILE_PTR)
-	n8.Tx32(address)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[FileSetPointer] response code error: %v", resp)
+func (n8 *N8) FileSetPointer(address uint32) error {
+	if err := n8.TxCmd(CMD_FILE_PTR); err != nil {
+		return err
 	}
+	if err := n8.Tx32(address); err != nil {
+		return err
+	}
+
+	return n8.checkStatus("FileSetPointer", CMD_FILE_PTR)
 }
 
 // DirOpen opens a directory on the N8 for reading.
 //
 // Sends a command to open the specified directory on the N8 device.
-func (n8 *N8) DirOpen(path string) {
-	n8.TxCmd(CMD_FILE_DIR_OPEN)
-	n8.TxString(path)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[DirOpen] status error: %v", resp)
+func (n8 *N8) DirOpen(path string) error {
+	if err := n8.TxCmd(CMD_FILE_DIR_OPEN); err != nil {
+		return err
 	}
+	if err := n8.TxString(path); err != nil {
+		return err
+	}
+
+	return n8.checkStatus("DirOpen", CMD_FILE_DIR_OPEN)
 }
 
 // DirLoad loads a directory listing from the N8.
 //
 // Sends a command to load a directory listing from specified path.
-func (n8 *N8) DirLoad(path string, sorted uint8) {
-	n8.TxCmd(CMD_FILE_DIR_LD)
-	n8.Tx8(sorted)
-	n8.TxString(path)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[DirLoad] status error: %v", resp)
+func (n8 *N8) DirLoad(path string, sorted uint8) error {
+	if err := n8.TxCmd(CMD_FILE_DIR_LD); err != nil {
+		return err
+	}
+	if err := n8.Tx8(sorted); err != nil {
+		return err
 	}
+	if err := n8.TxString(path); err != nil {
+		return err
+	}
+
+	return n8.checkStatus("DirLoad", CMD_FILE_DIR_LD)
 }
 
 // GetDirSize retrieves number of entries in currently open directory on the N8.
 //
 // Sends command to retrieve number of entries in the currently open directory,
 // returns number of entries as `uint16`.
-func (n8 *N8) GetDirSize() uint16 {
-	n8.TxCmd(CMD_FILE_DIR_SIZE)
+func (n8 *N8) GetDirSize() (uint16, error) {
+	if err := n8.TxCmd(CMD_FILE_DIR_SIZE); err != nil {
+		return 0, err
+	}
 
 	return n8.Rx16()
 }
 
+//
+// Directory Listing
+//
+
+// ReadDir returns the entries of a directory on the SD card.
+//
+// Loads the directory on the N8, then fetches its entries in batches of
+// DIR_BATCH_SIZE. Entries are in the order the N8 lists them.
+func (n8 *N8) ReadDir(path string) ([]FileInfo, error) {
+	if err := n8.DirLoad(path, 0); err != nil {
+		return nil, err
+	}
+
+	size, err := n8.GetDirSize()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]FileInfo, 0, size)
+	for start := (uint16)(0); start < size; start += DIR_BATCH_SIZE {
+		records, err := n8.GetDirRecords(start, min(DIR_BATCH_SIZE, size-start), 0xffff)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, records...)
+	}
+
+	return entries, nil
+}
+
+// WalkDirFunc is called by WalkDir for every entry it visits.
+//
+// `path` is the SD path of the entry. If reading a directory fails `fn`
+// is called again for that directory with the error. Returning
+// `fs.SkipDir` for a directory skips its contents.
+type WalkDirFunc func(path string, info *FileInfo, err error) error
+
+// WalkDir walks the directory tree below root on the SD card.
+//
+// Entries in each directory are visited in the order the N8 lists them,
+// directories before their contents. root itself is not visited, an
+// error reading it is returned directly.
+func (n8 *N8) WalkDir(root string, fn WalkDirFunc) error {
+	entries, err := n8.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	return n8.walkEntries(root, entries, fn)
+}
+
+// walkEntries visits the entries of dir and everything below them.
+func (n8 *N8) walkEntries(dir string, entries []FileInfo, fn WalkDirFunc) error {
+	for i := range entries {
+		entry := &entries[i]
+		isDir := entry.IsDir()
+
+		err := n8.walk(path.Join(dir, entry.Name), entry, fn)
+		if errors.Is(err, fs.SkipDir) {
+			if isDir {
+				continue
+			}
+			return nil // skip the rest of dir
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walk visits a single entry, then the contents of directories.
+func (n8 *N8) walk(entryPath string, entry *FileInfo, fn WalkDirFunc) error {
+	if err := fn(entryPath, entry, nil); err != nil || !entry.IsDir() {
+		return err
+	}
+
+	entries, err := n8.ReadDir(entryPath)
+	if err != nil {
+		return fn(entryPath, entry, err)
+	}
+
+	return n8.walkEntries(entryPath, entries, fn)
+}
+
 //
 // File Read Operations
 //
 
 // ReadFile reads data from a file on the N8.
 //
-// Reads data from a file in chunks of up to 4096 bytes.
-func (n8 *N8) ReadFile(buf []uint8, length uint32) {
-	n8.TxCmd(CMD_FILE_READ)
-	n8.Tx32(length)
+// Reads data from a file in blocks of up to FILE_BLOCK_SIZE bytes.
+func (n8 *N8) ReadFile(buf []uint8, length uint32) error {
+	if err := n8.TxCmd(CMD_FILE_READ); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
 
-	const chunkSize uint32 = 0x1000
+	progress := n8.startTransfer("ReadFile", length)
 	for length > 0 {
-		currentChunk := chunkSize
+		currentChunk := min(length, FILE_BLOCK_SIZE)
 
-		if length < chunkSize {
-			currentChunk = length
+		if err := n8.rxResp("ReadFile", CMD_FILE_READ); err != nil {
+			return err
 		}
-		tempBuf := make([]uint8, currentChunk)
 
-		resp := n8.Rx8()
-		if resp != 0 {
-			log.Fatalf("[ReadFile] file read error: %v", resp)
+		if err := n8.RxData(buf[:currentChunk]); err != nil {
+			return err
 		}
-
-		n8.RxData(tempBuf)
-		copy(buf[:currentChunk], tempBuf)
 		buf = buf[currentChunk:]
 
 		length -= currentChunk
+		progress.add(currentChunk)
 	}
+
+	return nil
 }
 
 // ReadFileFromMemory reads file data from memory on the N8.
 //
 // Reads file data from memory in chunks of up to 4096 bytes.
-func (n8 *N8) ReadFileFromMemory(address uint32, length uint32) {
+func (n8 *N8) ReadFileFromMemory(address uint32, length uint32) error {
+	progress := n8.startTransfer("ReadFileFromMemory", length)
 	const chunkSize uint32 = 0x1000
 	for length > 0 {
 		currentChunk := chunkSize
@@ -254,19 +383,29 @@ func (n8 *N8) ReadFileFromMemory(address uint32, length uint32) {
 			currentChunk = length
 		}
 
-		n8.TxCmd(CMD_FILE_READ_MEM)
-		n8.Tx32(address)
-		n8.Tx32(currentChunk)
-		n8.TxCmdExec()
+		if err := n8.TxCmd(CMD_FILE_READ_MEM); err != nil {
+			return err
+		}
+		if err := n8.Tx32(address); err != nil {
+			return err
+		}
+		if err := n8.Tx32(currentChunk); err != nil {
+			return err
+		}
+		if err := n8.TxCmdExec(); err != nil {
+			return err
+		}
 
-		ok, resp := n8.IsStatusOkay()
-		if !ok {
-			log.Fatalf("[ReadFileFromMemory] file read error: %v", resp)
+		if err := n8.checkStatus("ReadFileFromMemory", CMD_FILE_READ_MEM); err != nil {
+			return err
 		}
 
 		length -= currentChunk
 		address += currentChunk
+		progress.add(currentChunk)
 	}
+
+	return nil
 }
 
 //
@@ -276,21 +415,25 @@ func (n8 *N8) ReadFileFromMemory(address uint32, length uint32) {
 // FileWrite writes data to a file on the N8.
 //
 // Sends a file write command to the device along with the data to be written.
-func (n8 *N8) FileWrite(buf []uint8, length uint32) {
-	n8.TxCmd(CMD_FILE_WRITE)
-	n8.Tx32(length)
-	n8.TxDataACK(buf, length)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[FileWrite] file write error: %v", resp)
+func (n8 *N8) FileWrite(buf []uint8, length uint32) error {
+	if err := n8.TxCmd(CMD_FILE_WRITE); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
+	if err := n8.txDataACK("FileWrite", buf, length); err != nil {
+		return err
 	}
+
+	return n8.checkStatus("FileWrite", CMD_FILE_WRITE)
 }
 
 // FileWriteFromMemory writes data from memory to a file on the N8.
 //
 // Writes data from memory to a file in chunks of up to 4096 bytes.
-func (n8 *N8) FileWriteFromMemory(address uint32, length uint32) {
+func (n8 *N8) FileWriteFromMemory(address uint32, length uint32) error {
+	progress := n8.startTransfer("FileWriteFromMemory", length)
 	const chunkSize uint32 = 0x1000
 	for length > 0 {
 		currentChunk := chunkSize
@@ -298,52 +441,68 @@ func (n8 *N8) FileWriteFromMemory(address uint32, length uint32) {
 			currentChunk = length
 		}
 
-		n8.TxCmd(CMD_FILE_WRITE_MEM)
-		n8.Tx32(address)
-		n8.Tx32(currentChunk)
-		n8.TxCmdExec()
+		if err := n8.TxCmd(CMD_FILE_WRITE_MEM); err != nil {
+			return err
+		}
+		if err := n8.Tx32(address); err != nil {
+			return err
+		}
+		if err := n8.Tx32(currentChunk); err != nil {
+			return err
+		}
+		if err := n8.TxCmdExec(); err != nil {
+			return err
+		}
 
-		ok, resp := n8.IsStatusOkay()
-		if !ok {
-			log.Fatalf("[FileWriteFromMemory] file write error: %v", resp)
+		if err := n8.checkStatus("FileWriteFromMemory", CMD_FILE_WRITE_MEM); err != nil {
+			return err
 		}
 
 		length -= currentChunk
 		address += currentChunk
+		progress.add(currentChunk)
 	}
+
+	return nil
 }
 
 // mkdir creates a new directory on the N8 device.
 //
 // Sends a command to create a new directory at the specified path on the N8 device.
-func (n8 *N8) mkdir(path string) {
-	n8.TxCmd(CMD_FILE_DIR_MK)
-	n8.TxString(path)
+func (n8 *N8) mkdir(path string) error {
+	if err := n8.TxCmd(CMD_FILE_DIR_MK); err != nil {
+		return err
+	}
+	if err := n8.TxString(path); err != nil {
+		return err
+	}
 
-	ok, status := n8.IsStatusOkay()
+	ok, status, err := n8.IsStatusOkay()
+	if err != nil {
+		return err
+	}
 	if !ok {
-		if status == 8 {
-			return // directory already exists, no action needed
+		if status == FAT_EXIST {
+			return nil // directory already exists, no action needed
 		}
-		log.Fatalf("[mkdir] status error: %v", status)
+		return &StatusError{Op: "mkdir", Cmd: CMD_FILE_DIR_MK, Status: status}
 	}
+
+	return nil
 }
 
 // DeleteRecord deletes a file or directory from the N8.
 //
 // Sends a file delete command to the device with the specified path.
-func (n8 *N8) DeleteRecord(path string) {
-	n8.TxCmd(CMD_FILE_DEL)
-	n8.TxString(path)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		if resp == DELETE_FILE_NOT_FOUND {
-			fmt.Printf("[DeleteRecord] could not delete %s, file doesn't exist.\n", path)
-			return
-		}
-		log.Fatalf("[DeleteRecord] status response error: %v", resp)
+func (n8 *N8) DeleteRecord(path string) error {
+	if err := n8.TxCmd(CMD_FILE_DEL); err != nil {
+		return err
+	}
+	if err := n8.TxString(path); err != nil {
+		return err
 	}
+
+	return n8.checkStatus("DeleteRecord", CMD_FILE_DEL)
 }
 
 //
@@ -353,37 +512,45 @@ func (n8 *N8) DeleteRecord(path string) {
 // DiskInit initializes the disk on the N8.
 //
 // Sends a disk initialization command to the device and checks response.
-func (n8 *N8) DiskInit() {
-	n8.TxCmd(CMD_DISK_INIT)
-
-	ok, resp := n8.IsStatusOkay()
-	if !ok {
-		log.Fatalf("[DiskInit] status response error: %v", resp)
+func (n8 *N8) DiskInit() error {
+	if err := n8.TxCmd(CMD_DISK_INIT); err != nil {
+		return err
 	}
+
+	return n8.checkStatus("DiskInit", CMD_DISK_INIT)
 }
 
 // DiskRead reads data from the disk into the provided buffer.
 //
 // Sends a command to read data from the disk starting at the specified address,
-// reads it in chunks of up to 512 bytes until the specified length is read.
-func (n8 *N8) DiskRead(buf []uint8, address uint32, length uint32) {
-	n8.TxCmd(CMD_DISK_READ)
-	n8.Tx32(address)
-	n8.Tx32(length)
+// reads it a SECTOR_SIZE sector at a time until length sectors are read.
+// `DiskReadTo` streams sectors to an io.Writer instead.
+func (n8 *N8) DiskRead(buf []uint8, address uint32, length uint32) error {
+	if err := n8.TxCmd(CMD_DISK_READ); err != nil {
+		return err
+	}
+	if err := n8.Tx32(address); err != nil {
+		return err
+	}
+	if err := n8.Tx32(length); err != nil {
+		return err
+	}
 
+	progress := n8.startTransfer("DiskRead", length*SECTOR_SIZE)
 	var i uint32
 	for i = 0; i < length; i++ {
-		resp := n8.Rx8()
-		if resp != 0 {
-			log.Fatalf("[DiskRead] disk read error: %v", resp)
+		if err := n8.rxResp("DiskRead", CMD_DISK_READ); err != nil {
+			return err
 		}
 
-		tempBuf := make([]uint8, 512)
-		n8.RxData(tempBuf)
-
-		copy(buf[:512], tempBuf)
-		buf = buf[512:]
+		if err := n8.RxData(buf[:SECTOR_SIZE]); err != nil {
+			return err
+		}
+		buf = buf[SECTOR_SIZE:]
+		progress.add(SECTOR_SIZE)
 	}
+
+	return nil
 }
 
 //
@@ -0,0 +1,127 @@
+package n8_test
+
+import (
+	"bytes"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestFileWriteRead(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	data := testData(0x2345)
+	if err := dev.OpenFile("test.bin", n8.FAT_CREATE_ALWAYS|n8.FAT_WRITE); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.FileWrite(data, (uint32)(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.CloseFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := device.ReadFile("test.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Fatal("FileWrite: SD card file does not match")
+	}
+
+	info, err := dev.GetFileInfo("test.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "test.bin" || info.Size != (uint32)(len(data)) || info.IsDir() {
+		t.Errorf("GetFileInfo = %+v", info)
+	}
+
+	got := make([]uint8, len(data))
+	if err := dev.OpenFile("test.bin", n8.FAT_READ); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.ReadFile(got, (uint32)(len(got))); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.FileSetPointer(0); err != nil {
+		t.Fatal(err)
+	}
+	crc, err := dev.FileCrc((uint32)(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.CloseFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Error("ReadFile: data does not match")
+	}
+	if want := crc32.ChecksumIEEE(data); crc != want {
+		t.Errorf("FileCrc = %08X, want %08X", crc, want)
+	}
+}
+
+func TestGetFileInfoNotFound(t *testing.T) {
+	dev, _ := newEmulatedN8(t)
+
+	if _, err := dev.GetFileInfo("missing.bin"); !n8.IsNotFound(err) {
+		t.Fatalf("GetFileInfo of a missing file = %v, want a not found error", err)
+	}
+}
+
+func TestCopyFolderFromSD(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	// the emulator dates files with its RTC, far from the host clock
+	if err := dev.SetRtc(time.Date(2020, 5, 4, 10, 20, 30, 0, time.Local)); err != nil {
+		t.Fatal(err)
+	}
+	// SetRtc has no reply, wait for the device to have handled it
+	if _, err := dev.GetRtc(); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]uint8{
+		"a.bin":       testData(0x10),
+		"sub/b.bin":   testData((int)(n8.STREAM_CHUNK_SIZE) + 0x10),
+		"sub/c/d.bin": testData(0),
+	}
+	for name, data := range files {
+		if err := device.WriteFile("GAMES/"+name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	destination := filepath.Join(t.TempDir(), "GAMES")
+	if err := dev.CopyFolderFromSD("GAMES", destination); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		got, err := os.ReadFile(filepath.Join(destination, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: contents do not match", name)
+		}
+	}
+	for _, name := range []string{"a.bin", "sub", "sub/b.bin", "sub/c", "sub/c/d.bin"} {
+		info, err := dev.GetFileInfo("GAMES/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat, err := os.Stat(filepath.Join(destination, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ModTime().Year() != 2020 || !stat.ModTime().Equal(info.ModTime()) {
+			t.Errorf("%s: host time %s, SD card time %s", name, stat.ModTime(), info.ModTime())
+		}
+	}
+}
@@ -1,17 +1,62 @@
 package n8
 
 import (
+	"bufio"
+	"context"
 	"encoding/binary"
 	"fmt"
-	"log"
 	"time"
-
-	"github.com/tarm/serial"
 )
 
 type N8 struct {
 	Address string
-	Port    *serial.Port
+	Port    Transport
+	Trace   *Tracer
+
+	// Rediscover makes bootWait look for the N8 again if its serial
+	// device disappears while it reboots.
+	Rediscover bool
+
+	// ChunkSize fixes the number of bytes requested per `CMD_MEM_RD`,
+	// if 0 the chunk size adapts between MEM_CHUNK_MIN and MEM_CHUNK_MAX.
+	ChunkSize uint32
+
+	// Progress is called during chunked transfers, it may be nil.
+	Progress ProgressFunc
+
+	// Verify checks every file written to or read from the SD card
+	// against a CRC calculated by the N8, retrying on a mismatch.
+	Verify bool
+
+	// Resume makes CopyFile keep the part of an existing destination
+	// file that matches the source and copy only the rest.
+	Resume bool
+
+	// OpTimeout aborts a command, or a chunk of a transfer, that takes
+	// longer than this. 0 means no limit. Set it before `InitSerial`,
+	// which lowers the serial read timeout to match so a stalled read is
+	// noticed in time; a transport passed to `NewN8` needs a read timeout
+	// no longer than OpTimeout for the same.
+	OpTimeout time.Duration
+
+	cmd      uint8 // last command sent, for tracing
+	memChunk uint32
+	rx       *bufio.Reader
+	rxPort   Transport
+
+	ctx         context.Context
+	deadline    time.Time
+	interrupted bool   // an operation was aborted part way through
+	pending     uint32 // bytes the N8 still expects for an aborted write
+	fileOpen    bool
+	resyncing   bool // stops framing errors starting a resync, eg. during one
+
+	stream *transfer // progress of the current WriteFileFrom or ReadFileTo
+}
+
+// NewN8 returns an N8 that talks to the device over the given transport.
+func NewN8(port Transport) *N8 {
+	return &N8{Port: port}
 }
 
 //
@@ -28,10 +73,9 @@ type Vdc struct {
 }
 
 // NewVdc creates a new Vdc struct from the given data.
-func NewVdc(data []uint8) *Vdc {
+func NewVdc(data []uint8) (*Vdc, error) {
 	if len(data) != VDC_DATA_SIZE {
-		log.Fatalf("[NewVdc] invalid data length, expected 8, got %d", len(data))
-
+		return nil, fmt.Errorf("[NewVdc] invalid data length, expected 8, got %d", len(data))
 	}
 
 	return &Vdc{
@@ -39,7 +83,7 @@ func NewVdc(data []uint8) *Vdc {
 		V25: binary.LittleEndian.Uint16(data[2:4]),
 		V12: binary.LittleEndian.Uint16(data[4:6]),
 		Vbt: binary.LittleEndian.Uint16(data[6:8]),
-	}
+	}, nil
 }
 
 //
@@ -88,10 +132,9 @@ func NewRtcTime(dt time.Time) *RtcTime {
 }
 
 // NewRtcTimeFromSerial returns new RtcTime from serialized RTC data.
-func NewRtcTimeFromSerial(data []uint8) *RtcTime {
+func NewRtcTimeFromSerial(data []uint8) (*RtcTime, error) {
 	if len(data) != RTC_DATA_SIZE {
-		log.Fatalf("[NewRtcTimeFromSerial] invalid data length, expected 8, got %d", len(data))
-
+		return nil, fmt.Errorf("[NewRtcTimeFromSerial] invalid data length, expected 6, got %d", len(data))
 	}
 
 	return &RtcTime{
@@ -101,7 +144,7 @@ func NewRtcTimeFromSerial(data []uint8) *RtcTime {
 		Hour:   data[3],
 		Minute: data[4],
 		Second: data[5],
-	}
+	}, nil
 }
 
 //
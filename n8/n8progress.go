@@ -0,0 +1,85 @@
+package n8
+
+import "time"
+
+// Progress describes how far a chunked transfer has got.
+//
+// `Op` is the name of the operation doing the transfer, eg. "ReadFile".
+type Progress struct {
+	Op      string
+	Done    uint64
+	Total   uint64
+	Elapsed time.Duration
+}
+
+// ProgressFunc is called as a transfer starts and after every chunk.
+type ProgressFunc func(Progress)
+
+// Rate returns the throughput so far in bytes per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+
+	return (float64)(p.Done) / p.Elapsed.Seconds()
+}
+
+// transfer tracks a single chunked operation for progress reporting.
+type transfer struct {
+	n8    *N8
+	op    string
+	done  uint64
+	total uint64
+	start time.Time
+}
+
+// startTransfer reports the start of a transfer of total bytes, 0 if
+// the total is not known.
+//
+// Inside a stream the stream's transfer is returned instead, so its
+// chunks are reported as one transfer.
+func (n8 *N8) startTransfer(op string, total uint32) *transfer {
+	if n8.stream != nil {
+		return n8.stream
+	}
+
+	t := &transfer{n8: n8, op: op, total: (uint64)(total), start: time.Now()}
+	t.report()
+
+	return t
+}
+
+// add reports another n bytes done.
+func (t *transfer) add(n uint32) {
+	t.done += (uint64)(n)
+	t.n8.resetDeadline()
+	t.report()
+}
+
+// undo takes back n bytes reported done, for a chunk that is sent again.
+func (t *transfer) undo(n uint32) {
+	if t != nil {
+		t.done -= (uint64)(n)
+	}
+}
+
+// end reports a transfer whose total was not known as complete.
+func (t *transfer) end() {
+	if t.total == 0 && t.done > 0 {
+		t.total = t.done
+		t.report()
+	}
+}
+
+func (t *transfer) report() {
+	if t.n8.Progress == nil {
+		return
+	}
+
+	t.n8.Progress(Progress{
+		Op:      t.op,
+		Done:    t.done,
+		Total:   t.total,
+		Elapsed: time.Since(t.start),
+	})
+}
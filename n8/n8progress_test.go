@@ -0,0 +1,103 @@
+package n8_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+	"forge.rights.ninja/jeff/goedlink/nesrom"
+)
+
+// recordProgress collects every progress report made by dev.
+func recordProgress(dev *n8.N8) *[]n8.Progress {
+	var reports []n8.Progress
+	dev.Progress = func(p n8.Progress) {
+		reports = append(reports, p)
+	}
+
+	return &reports
+}
+
+// checkProgress checks reports are a single transfer by op that starts
+// at 0 and counts up to total.
+func checkProgress(t *testing.T, reports []n8.Progress, op string, total uint64) {
+	t.Helper()
+
+	if len(reports) < 2 {
+		t.Fatalf("%d progress reports, want a start and at least one chunk", len(reports))
+	}
+	if reports[0].Done != 0 {
+		t.Errorf("first report has %d bytes done, want 0", reports[0].Done)
+	}
+	for i, report := range reports {
+		if report.Op != op {
+			t.Errorf("report %d is for %s, want %s", i, report.Op, op)
+		}
+		if i > 0 && report.Done < reports[i-1].Done {
+			t.Errorf("report %d went back from %d to %d bytes", i, reports[i-1].Done, report.Done)
+		}
+	}
+	if last := reports[len(reports)-1]; last.Done != total || last.Total != total {
+		t.Errorf("last report %d of %d bytes, want %d of %d", last.Done, last.Total, total, total)
+	}
+}
+
+func TestProgressMemory(t *testing.T) {
+	dev, _ := newEmulatedN8(t)
+	data := testData(0x30000)
+
+	reports := recordProgress(dev)
+	if err := dev.WriteMemory(nesrom.ADDR_PRG, data, (uint32)(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "WriteMemory", (uint64)(len(data)))
+
+	*reports = nil
+	if err := dev.ReadMemory(nesrom.ADDR_PRG, make([]uint8, len(data)), (uint32)(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "ReadMemory", (uint64)(len(data)))
+}
+
+func TestProgressStream(t *testing.T) {
+	dev, _ := newEmulatedN8(t)
+	data := testData((int)(n8.STREAM_CHUNK_SIZE)*2 + 0x10)
+
+	// chunks of a stream are reported as one transfer
+	reports := recordProgress(dev)
+	if _, err := dev.WriteFileFrom("known.bin", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "FileWrite", (uint64)(len(data)))
+	for _, report := range *reports {
+		if report.Total != (uint64)(len(data)) {
+			t.Fatalf("known size reported as %d bytes", report.Total)
+		}
+	}
+
+	// a size that is not known is reported as 0 until the end
+	*reports = nil
+	if _, err := dev.WriteFileFrom("unknown.bin", io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "FileWrite", (uint64)(len(data)))
+	if (*reports)[len(*reports)-2].Total != 0 {
+		t.Errorf("unknown size reported as %d bytes before the end", (*reports)[len(*reports)-2].Total)
+	}
+}
+
+func TestProgressCopyWithinSD(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+	data := testData((int)(n8.STREAM_CHUNK_SIZE) + 0x10)
+	if err := device.WriteFile("a.bin", data); err != nil {
+		t.Fatal(err)
+	}
+
+	// every byte is read from the source and written to the destination
+	reports := recordProgress(dev)
+	if err := dev.CopyFile("sd:a.bin", "sd:b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, *reports, "CopyFile", (uint64)(len(data))*2)
+}
@@ -0,0 +1,118 @@
+package n8
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReplayTransport is a Transport that plays back a trace written by a
+// Tracer, so a recorded session can be reproduced without hardware.
+//
+// Writes must match the recorded host data byte for byte. Recorded
+// replies only become readable once everything the host sent before
+// them has been written again, reads past that point time out, as do
+// reads that timed out when the trace was recorded.
+type ReplayTransport struct {
+	tx    []uint8
+	txPos int
+	rx    []replayChunk
+	rxPos int
+}
+
+// replayChunk is reply data and how much host data preceded it. A
+// timeout chunk has no data and makes one read return nothing.
+type replayChunk struct {
+	after   int
+	data    []uint8
+	timeout bool
+}
+
+// NewReplayTransport loads a trace from r.
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	t := &ReplayTransport{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("[NewReplayTransport] line %d: %w", line, err)
+		}
+		data, err := hex.DecodeString(event.Data)
+		if err != nil {
+			return nil, fmt.Errorf("[NewReplayTransport] line %d: %w", line, err)
+		}
+
+		switch event.Dir {
+		case TRACE_TX:
+			t.tx = append(t.tx, data...)
+		case TRACE_RX:
+			if len(data) > 0 {
+				t.rx = append(t.rx, replayChunk{after: len(t.tx), data: data})
+			}
+			if event.Timeout {
+				t.rx = append(t.rx, replayChunk{after: len(t.tx), timeout: true})
+			}
+		default:
+			return nil, fmt.Errorf("[NewReplayTransport] line %d: unknown direction %q", line, event.Dir)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[NewReplayTransport] %w", err)
+	}
+
+	return t, nil
+}
+
+// Read returns the next recorded reply data.
+func (t *ReplayTransport) Read(buf []uint8) (int, error) {
+	if t.rxPos >= len(t.rx) || t.rx[t.rxPos].after > t.txPos {
+		return 0, io.EOF
+	}
+
+	chunk := &t.rx[t.rxPos]
+	if chunk.timeout {
+		t.rxPos++
+		return 0, io.EOF
+	}
+	n := copy(buf, chunk.data)
+	chunk.data = chunk.data[n:]
+	if len(chunk.data) == 0 {
+		t.rxPos++
+	}
+
+	return n, nil
+}
+
+// Write checks buf against the recorded host data.
+func (t *ReplayTransport) Write(buf []uint8) (int, error) {
+	for i, b := range buf {
+		if t.txPos >= len(t.tx) {
+			return i, fmt.Errorf("[ReplayTransport] write past end of trace at byte %d", t.txPos)
+		}
+		if t.tx[t.txPos] != b {
+			return i, fmt.Errorf("[ReplayTransport] trace diverged at byte %d: wrote %02x, recorded %02x", t.txPos, b, t.tx[t.txPos])
+		}
+		t.txPos++
+	}
+
+	return len(buf), nil
+}
+
+// Close does nothing, the trace stays loaded.
+func (t *ReplayTransport) Close() error {
+	return nil
+}
+
+// Reopen does nothing, the trace keeps playing where it left off.
+func (t *ReplayTransport) Reopen(timeout time.Duration) error {
+	return nil
+}
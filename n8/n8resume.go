@@ -0,0 +1,108 @@
+package n8
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// matchedLength returns how much of a file on the SD card matches the
+// first length bytes of host.
+//
+// CRCs are compared a STREAM_CHUNK_SIZE chunk at a time, so the result
+// is a multiple of STREAM_CHUNK_SIZE unless all length bytes match.
+func (n8 *N8) matchedLength(sdPath string, host io.ReaderAt, length uint32) (uint32, error) {
+	if length == 0 {
+		return 0, nil
+	}
+	file, err := n8.Open(sdPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	buf := make([]uint8, min(length, STREAM_CHUNK_SIZE))
+	var matched uint32
+	for matched < length {
+		chunk := buf[:min(length-matched, STREAM_CHUNK_SIZE)]
+		if _, err := host.ReadAt(chunk, (int64)(matched)); err != nil {
+			return 0, fmt.Errorf("[Resume] error reading host file: %w", err)
+		}
+
+		crc, err := file.Crc((uint32)(len(chunk)))
+		if err != nil {
+			return 0, err
+		}
+		if crc != crc32.ChecksumIEEE(chunk) {
+			break
+		}
+		matched += (uint32)(len(chunk))
+	}
+
+	return matched, file.Close()
+}
+
+// resumeUpload copies source to a file on the SD card, keeping as much
+// of an existing, partly written, file as matches source.
+func (n8 *N8) resumeUpload(source *os.File, sdPath string) error {
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("[Resume] %w", err)
+	}
+
+	var matched uint32
+	sdInfo, err := n8.GetFileInfo(sdPath)
+	switch {
+	case IsNotFound(err):
+	case err != nil:
+		return err
+	case !sdInfo.IsDir() && (int64)(sdInfo.Size) <= info.Size():
+		if matched, err = n8.matchedLength(sdPath, source, sdInfo.Size); err != nil {
+			return err
+		}
+	}
+
+	if _, err := source.Seek((int64)(matched), io.SeekStart); err != nil {
+		return fmt.Errorf("[Resume] %w", err)
+	}
+	_, err = n8.writeFileFrom(sdPath, matched, source)
+	return err
+}
+
+// resumeDownload copies a file from the SD card to the host, keeping as
+// much of an existing, partly written, destination as matches it.
+func (n8 *N8) resumeDownload(source string, destination string, info *FileInfo) error {
+	file, err := os.OpenFile(destination, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("[CopyFile] error opening destination: %w", err)
+	}
+	defer file.Close()
+
+	hostInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("[Resume] %w", err)
+	}
+
+	var matched uint32
+	if hostInfo.Size() <= (int64)(info.Size) {
+		if matched, err = n8.matchedLength(source, file, (uint32)(hostInfo.Size())); err != nil {
+			return err
+		}
+	}
+
+	if err := file.Truncate((int64)(matched)); err != nil {
+		return fmt.Errorf("[Resume] %w", err)
+	}
+	if _, err := file.Seek((int64)(matched), io.SeekStart); err != nil {
+		return fmt.Errorf("[Resume] %w", err)
+	}
+	if _, err := n8.readFileTo(source, matched, file); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("[CopyFile] error writing to destination: %w", err)
+	}
+
+	return setHostTime(destination, info.ModTime())
+}
@@ -0,0 +1,104 @@
+package n8_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// countingTransport counts the bytes written and read.
+type countingTransport struct {
+	n8.Transport
+	written int
+	read    int
+}
+
+func (t *countingTransport) Write(buf []uint8) (int, error) {
+	n, err := t.Transport.Write(buf)
+	t.written += n
+	return n, err
+}
+
+func (t *countingTransport) Read(buf []uint8) (int, error) {
+	n, err := t.Transport.Read(buf)
+	t.read += n
+	return n, err
+}
+
+// resumeData returns a file of two and a half stream chunks, and a
+// partial copy of it whose second chunk differs.
+func resumeData() ([]uint8, []uint8) {
+	chunk := (int)(n8.STREAM_CHUNK_SIZE)
+	data := testData(chunk*2 + chunk/2)
+
+	partial := bytes.Clone(data[:chunk+chunk/2])
+	partial[chunk+chunk/4] ^= 0xFF
+
+	return data, partial
+}
+
+func TestResumeUpload(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+	transport := &countingTransport{Transport: dev.Port}
+	dev.Port = transport
+	dev.Resume = true
+
+	data, partial := resumeData()
+	source := filepath.Join(t.TempDir(), "game.bin")
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.WriteFile("game.bin", partial); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.CopyFile(source, "sd:game.bin"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := device.ReadFile("game.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Fatal("resumed upload does not match")
+	}
+
+	// the first chunk matched, so only the rest is sent
+	if want := len(data) - (int)(n8.STREAM_CHUNK_SIZE); transport.written > want+0x1000 {
+		t.Errorf("resumed upload sent %d bytes, want about %d", transport.written, want)
+	}
+}
+
+func TestResumeDownload(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+	transport := &countingTransport{Transport: dev.Port}
+	dev.Port = transport
+	dev.Resume = true
+
+	data, partial := resumeData()
+	if err := device.WriteFile("game.bin", data); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(t.TempDir(), "game.bin")
+	if err := os.WriteFile(destination, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.CopyFile("sd:game.bin", destination); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("resumed download does not match")
+	}
+
+	if want := len(data) - (int)(n8.STREAM_CHUNK_SIZE); transport.read > want+0x1000 {
+		t.Errorf("resumed download received %d bytes, want about %d", transport.read, want)
+	}
+}
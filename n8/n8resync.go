@@ -0,0 +1,121 @@
+package n8
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const RESYNC_ATTEMPTS = 3
+
+// PAD_BLOCK_SIZE is the number of zeros pad writes between reads.
+const PAD_BLOCK_SIZE uint32 = 0x10000
+
+// Cleanup returns the N8 to a known state after an aborted operation.
+//
+// Resyncs the link if an operation was aborted part way through, then
+// closes the open file. Runs without the bound context or `OpTimeout`,
+// and does nothing if the last operation finished normally.
+func (n8 *N8) Cleanup() error {
+	if !n8.interrupted && !n8.fileOpen && n8.pending == 0 {
+		return nil
+	}
+
+	ctx, timeout := n8.ctx, n8.OpTimeout
+	n8.ctx, n8.OpTimeout, n8.deadline = nil, 0, time.Time{}
+	defer func() {
+		n8.ctx, n8.OpTimeout = ctx, timeout
+	}()
+
+	if n8.interrupted || n8.pending > 0 {
+		if err := n8.Resync(); err != nil {
+			return fmt.Errorf("[Cleanup] %w", err)
+		}
+	}
+
+	if n8.fileOpen {
+		// the file may never have opened, so a status error is expected
+		var statusErr *StatusError
+		if err := n8.CloseFile(); err != nil && !errors.As(err, &statusErr) {
+			return fmt.Errorf("[Cleanup] %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Resync restores framing with the N8 after a short read or an
+// unexpected reply.
+//
+// Finishes any write the N8 is still waiting on with zeros, discards
+// unread input, then sends `CMD_STATUS` until the N8 answers with a
+// valid 0xA5xx status. The library calls it automatically when it
+// detects a framing error.
+func (n8 *N8) Resync() error {
+	resyncing := n8.resyncing
+	n8.resyncing = true
+	defer func() {
+		n8.resyncing = resyncing
+	}()
+
+	if n8.pending > 0 {
+		length := n8.pending
+		n8.pending = 0
+		if err := n8.pad(length); err != nil {
+			return fmt.Errorf("[Resync] %w", err)
+		}
+	}
+
+	var resp uint16
+	for i := 0; i < RESYNC_ATTEMPTS; i++ {
+		n8.drain()
+
+		ok, status, err := n8.GetStatus()
+		if err != nil && !errors.Is(err, ErrTimeout) {
+			return fmt.Errorf("[Resync] %w", err)
+		}
+		if err == nil && ok {
+			n8.interrupted = false
+			return nil
+		}
+		resp = status
+	}
+
+	return fmt.Errorf("[Resync] no valid status after %d attempts, last reply: 0x%04X", RESYNC_ATTEMPTS, resp)
+}
+
+// autoResync resyncs after a framing error, unless a resync is already
+// running. Errors are ignored, the caller returns the original error.
+func (n8 *N8) autoResync() {
+	if !n8.resyncing {
+		n8.Resync()
+	}
+}
+
+// pad sends length zeros to finish an aborted write.
+//
+// The N8 acks every ACK_BLOCK_SIZE bytes it reads, the acks are
+// discarded after each PAD_BLOCK_SIZE block so they cannot fill the
+// receive buffer. Writes and reads take turns, the transport is never
+// used from two goroutines at once.
+func (n8 *N8) pad(length uint32) error {
+	zeros := make([]uint8, min(length, PAD_BLOCK_SIZE))
+	buf := make([]uint8, RX_BUFFER_SIZE)
+
+	for length > 0 {
+		block := min(length, PAD_BLOCK_SIZE)
+		if err := n8.write("Pad", zeros[:block]); err != nil {
+			return err
+		}
+		length -= block
+
+		if length > 0 {
+			start := time.Now()
+			n, err := n8.Port.Read(buf)
+			n8.trace(start, TRACE_RX, "Pad", buf[:n], err)
+		}
+	}
+	n8.drain()
+
+	return nil
+}
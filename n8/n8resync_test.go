@@ -0,0 +1,180 @@
+package n8_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// exclusiveTransport records whether it was ever used from two
+// goroutines at once, writes are held for a moment so an overlapping
+// read is caught.
+type exclusiveTransport struct {
+	n8.Transport
+	active  atomic.Int32
+	overlap atomic.Bool
+}
+
+func (t *exclusiveTransport) enter() {
+	if t.active.Add(1) > 1 {
+		t.overlap.Store(true)
+	}
+}
+
+func (t *exclusiveTransport) Read(buf []uint8) (int, error) {
+	t.enter()
+	defer t.active.Add(-1)
+	return t.Transport.Read(buf)
+}
+
+func (t *exclusiveTransport) Write(buf []uint8) (int, error) {
+	t.enter()
+	defer t.active.Add(-1)
+	time.Sleep(time.Millisecond * 10)
+	return t.Transport.Write(buf)
+}
+
+func TestCleanupAfterAbortedWrite(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+	transport := &exclusiveTransport{Transport: dev.Port}
+	dev.Port = transport
+	if err := dev.ReopenSerial(time.Millisecond * 200); err != nil {
+		t.Fatal(err)
+	}
+
+	// cancel once the first block is written, leaving the N8 waiting for
+	// the rest of the file
+	ctx, cancel := context.WithCancel(context.Background())
+	dev.SetContext(ctx)
+	dev.Progress = func(p n8.Progress) {
+		if p.Done > 0 {
+			cancel()
+		}
+	}
+
+	data := testData(0x30000)
+	if err := dev.OpenFile("abort.bin", n8.FAT_CREATE_ALWAYS|n8.FAT_WRITE); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.FileWrite(data, (uint32)(len(data))); !errors.Is(err, context.Canceled) {
+		t.Fatalf("FileWrite = %v, want context.Canceled", err)
+	}
+
+	dev.SetContext(context.Background())
+	dev.Progress = nil
+	if err := dev.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if transport.overlap.Load() {
+		t.Error("transport was used from two goroutines at once")
+	}
+
+	if ok, _, err := dev.GetStatus(); err != nil || !ok {
+		t.Fatalf("GetStatus after Cleanup = %v, %v", ok, err)
+	}
+	stored, err := device.ReadFile("abort.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != len(data) {
+		t.Errorf("padded file is %d bytes, want %d", len(stored), len(data))
+	}
+}
+
+func TestReplayResync(t *testing.T) {
+	// a write cancelled part way through leaves Cleanup to pad the rest
+	// and drain the acks before it resyncs
+	session := func(dev *n8.N8) error {
+		if err := dev.ReopenSerial(time.Millisecond * 200); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		dev.SetContext(ctx)
+		dev.Progress = func(p n8.Progress) {
+			if p.Done > 0 {
+				cancel()
+			}
+		}
+
+		data := testData(0x30000)
+		if err := dev.OpenFile("abort.bin", n8.FAT_CREATE_ALWAYS|n8.FAT_WRITE); err != nil {
+			return err
+		}
+		if err := dev.FileWrite(data, (uint32)(len(data))); !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("FileWrite = %v, want context.Canceled", err)
+		}
+
+		dev.SetContext(context.Background())
+		dev.Progress = nil
+		if err := dev.Cleanup(); err != nil {
+			return err
+		}
+		if ok, _, err := dev.GetStatus(); err != nil || !ok {
+			return fmt.Errorf("GetStatus after Cleanup = %v, %v", ok, err)
+		}
+		return nil
+	}
+
+	trace := recordSession(t, session)
+	if err := replaySession(t, trace, session); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+}
+
+// lateTransport returns nothing from its first read, as if the reply
+// arrived just after the read timed out.
+type lateTransport struct {
+	n8.Transport
+	late bool
+}
+
+func (t *lateTransport) Read(buf []uint8) (int, error) {
+	if !t.late {
+		t.late = true
+		return 0, nil
+	}
+	return t.Transport.Read(buf)
+}
+
+func TestReplayLateReply(t *testing.T) {
+	// the late reply is drained by the resync after the timeout
+	session := func(dev *n8.N8) error {
+		if err := dev.ReopenSerial(time.Millisecond * 200); err != nil {
+			return err
+		}
+		if _, _, err := dev.GetStatus(); !errors.Is(err, n8.ErrTimeout) {
+			return fmt.Errorf("first GetStatus = %v, want ErrTimeout", err)
+		}
+		if ok, _, err := dev.GetStatus(); err != nil || !ok {
+			return fmt.Errorf("GetStatus after resync = %v, %v", ok, err)
+		}
+		return nil
+	}
+
+	trace := recordSession(t, func(dev *n8.N8) error {
+		dev.Port = &lateTransport{Transport: dev.Port}
+		return session(dev)
+	})
+
+	drained := false
+	for _, line := range bytes.Split(trace, []uint8("\n")) {
+		var event n8.TraceEvent
+		if json.Unmarshal(line, &event) == nil && event.Call == "Drain" && event.Data != "" {
+			drained = true
+		}
+	}
+	if !drained {
+		t.Error("the drained reply is not in the trace")
+	}
+
+	if err := replaySession(t, trace, session); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package n8
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+)
+
+// SDFile is an open file on the SD card.
+//
+// The N8 can only have one file open at a time, opening another before
+// Close returns ErrFileBusy. Reads and writes go through the file
+// pointer on the N8, which is moved as needed to follow Seek.
+type SDFile struct {
+	n8      *N8
+	path    string
+	mode    uint8
+	size    uint32
+	offset  uint32 // where the next Read or Write happens
+	pointer uint32 // the file pointer on the N8
+	closed  bool
+}
+
+// Open opens a file on the SD card for reading.
+func (n8 *N8) Open(sdPath string) (*SDFile, error) {
+	return n8.openSDFile(sdPath, FAT_READ)
+}
+
+// Create creates or truncates a file on the SD card and opens it for
+// reading and writing.
+func (n8 *N8) Create(sdPath string) (*SDFile, error) {
+	return n8.openSDFile(sdPath, FAT_CREATE_ALWAYS|FAT_WRITE|FAT_READ)
+}
+
+// openSDFile opens a file with any mode accepted by OpenFile.
+func (n8 *N8) openSDFile(sdPath string, mode uint8) (*SDFile, error) {
+	if n8.fileOpen {
+		return nil, fmt.Errorf("[Open] sd:%s %w", sdPath, ErrFileBusy)
+	}
+	if n8.Verify && mode&FAT_WRITE != 0 {
+		mode |= FAT_READ // FileCrc reads the written data back
+	}
+
+	var size uint32
+	if mode&FAT_CREATE_ALWAYS == 0 {
+		info, err := n8.GetFileInfo(sdPath)
+		switch {
+		case err == nil && info.IsDir():
+			return nil, &fs.PathError{Op: "open", Path: sdPath, Err: errIsDir}
+		case err == nil:
+			size = info.Size
+		case !IsNotFound(err) || mode&(FAT_OPEN_ALWAYS|FAT_CREATE_NEW) == 0:
+			return nil, err
+		}
+	}
+
+	if err := n8.OpenFile(sdPath, mode); err != nil {
+		return nil, err
+	}
+	if err := n8.checkStatus("Open", CMD_FILE_OPEN); err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			n8.fileOpen = false
+		}
+		return nil, err
+	}
+
+	file := &SDFile{n8: n8, path: sdPath, mode: mode, size: size}
+	if mode&FAT_OPEN_APPEND == FAT_OPEN_APPEND {
+		file.offset, file.pointer = size, size
+	}
+
+	return file, nil
+}
+
+// Name returns the SD path the file was opened with.
+func (f *SDFile) Name() string {
+	return f.path
+}
+
+// Size returns the current size of the file.
+func (f *SDFile) Size() int64 {
+	return (int64)(f.size)
+}
+
+// Read reads up to len(buf) bytes, returning io.EOF at the end of the
+// file. Reads are checked if `N8.Verify` is set.
+func (f *SDFile) Read(buf []uint8) (int, error) {
+	if err := f.check("read", FAT_READ); err != nil {
+		return 0, err
+	}
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	length := f.size - f.offset
+	if (uint64)(len(buf)) < (uint64)(length) {
+		length = (uint32)(len(buf))
+	}
+	done := (uint32)(0)
+	for done < length {
+		chunk := buf[done:min(length, done+STREAM_CHUNK_SIZE)]
+		if err := f.seek(); err != nil {
+			return (int)(done), err
+		}
+		if err := f.n8.readChunk(f.path, f.offset, chunk); err != nil {
+			return (int)(done), err
+		}
+		f.advance((uint32)(len(chunk)))
+		done += (uint32)(len(chunk))
+	}
+
+	return (int)(done), nil
+}
+
+// Write writes buf at the current offset, growing the file as needed.
+// Writes are checked if `N8.Verify` was set when the file was opened.
+func (f *SDFile) Write(buf []uint8) (int, error) {
+	if err := f.check("write", FAT_WRITE); err != nil {
+		return 0, err
+	}
+	if (uint64)(f.offset)+(uint64)(len(buf)) > math.MaxUint32 {
+		return 0, fmt.Errorf("[Write] sd:%s would be larger than 4 GiB", f.path)
+	}
+
+	done := 0
+	for done < len(buf) {
+		chunk := buf[done:min(len(buf), done+(int)(STREAM_CHUNK_SIZE))]
+		if err := f.seek(); err != nil {
+			return done, err
+		}
+		if err := f.n8.writeChunk(f.path, f.offset, chunk); err != nil {
+			return done, err
+		}
+		f.advance((uint32)(len(chunk)))
+		f.size = max(f.size, f.offset)
+		done += len(chunk)
+	}
+
+	return done, nil
+}
+
+// Seek sets the offset of the next Read or Write. The N8 file pointer is
+// only moved when it is next needed.
+func (f *SDFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += (int64)(f.offset)
+	case io.SeekEnd:
+		offset += (int64)(f.size)
+	}
+	if offset < 0 || offset > math.MaxUint32 {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	f.offset = (uint32)(offset)
+	return offset, nil
+}
+
+// Crc returns the CRC of the next length bytes, as calculated by the N8,
+// and moves the offset past them.
+func (f *SDFile) Crc(length uint32) (uint32, error) {
+	if err := f.check("crc", FAT_READ); err != nil {
+		return 0, err
+	}
+	if err := f.seek(); err != nil {
+		return 0, err
+	}
+
+	crc, err := f.n8.FileCrc(length)
+	if err != nil {
+		return 0, err
+	}
+	f.advance(min(length, f.size-min(f.offset, f.size)))
+
+	return crc, nil
+}
+
+// Close closes the file on the N8.
+//
+// If a transfer was aborted part way through the N8 is left for
+// `N8.Cleanup`, which closes the file once the link is back in sync.
+func (f *SDFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.path, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	if f.n8.interrupted || f.n8.pending > 0 {
+		return nil
+	}
+	return f.n8.CloseFile()
+}
+
+// check returns an error if the file is closed or was not opened with
+// access.
+func (f *SDFile) check(op string, access uint8) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrClosed}
+	}
+	if f.mode&access == 0 {
+		return &fs.PathError{Op: op, Path: f.path, Err: fs.ErrPermission}
+	}
+
+	return nil
+}
+
+// seek moves the N8 file pointer to the offset, if it is not there.
+func (f *SDFile) seek() error {
+	if f.pointer == f.offset {
+		return nil
+	}
+	if err := f.n8.FileSetPointer(f.offset); err != nil {
+		return err
+	}
+
+	f.pointer = f.offset
+	return nil
+}
+
+func (f *SDFile) advance(n uint32) {
+	f.offset += n
+	f.pointer = f.offset
+}
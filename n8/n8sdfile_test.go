@@ -0,0 +1,66 @@
+package n8_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestSDFile(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	file, err := dev.Create("notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dev.Open("other.txt"); !errors.Is(err, n8.ErrFileBusy) {
+		t.Errorf("Open with a file already open = %v, want ErrFileBusy", err)
+	}
+
+	if _, err := file.Write([]uint8("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(7, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]uint8("there!")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello, there!"; string(got) != want || file.Size() != (int64)(len(want)) {
+		t.Errorf("read back %q (size %d), want %q", got, file.Size(), want)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]uint8("x")); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Write after Close = %v, want fs.ErrClosed", err)
+	}
+
+	stored, err := device.ReadFile("notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, []uint8("hello, there!")) {
+		t.Errorf("SD card file is %q", stored)
+	}
+
+	file, err = dev.Open("notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write([]uint8("x")); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Write to a read only file = %v, want fs.ErrPermission", err)
+	}
+}
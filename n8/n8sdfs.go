@@ -0,0 +1,299 @@
+package n8
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SDFS is the SD card of an N8 as an `fs.FS`.
+//
+// Names are slash separated and relative to the root of the card, "."
+// is the root. Calls are serialized so an SDFS can be shared between
+// goroutines, eg. by `http.FileServer`, but nothing else may use the N8
+// at the same time.
+type SDFS struct {
+	n8 *N8
+	mu sync.Mutex
+}
+
+// NewSDFS returns the SD card of n8 as a file system.
+func NewSDFS(n8 *N8) *SDFS {
+	return &SDFS{n8: n8}
+}
+
+// Open opens the named file or directory.
+//
+// Files are read a piece at a time as Read is called, so any number can
+// be open at once.
+func (sdfs *SDFS) Open(name string) (fs.File, error) {
+	info, err := sdfs.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &sdDir{sdfs: sdfs, name: name, info: info}, nil
+	}
+	return &sdFile{sdfs: sdfs, path: sdfsPath(name), info: info}, nil
+}
+
+// Stat returns information about the named file or directory.
+func (sdfs *SDFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := sdfs.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// ReadDir returns the entries of the named directory sorted by name.
+func (sdfs *SDFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !sdfsValid(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	sdfs.mu.Lock()
+	infos, err := sdfs.n8.ReadDir(sdfsPath(name))
+	sdfs.mu.Unlock()
+	if err != nil {
+		return nil, sdfsError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, len(infos))
+	for i := range infos {
+		entries[i] = fs.FileInfoToDirEntry(&fileInfo{info: infos[i]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// ReadFile reads the whole of the named file.
+func (sdfs *SDFS) ReadFile(name string) ([]uint8, error) {
+	info, err := sdfs.stat("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errIsDir}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow((int)(info.info.Size))
+
+	sdfs.mu.Lock()
+	_, err = sdfs.n8.ReadFileTo(name, &buf)
+	sdfs.mu.Unlock()
+	if err != nil {
+		return nil, sdfsError("readfile", name, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (sdfs *SDFS) stat(op string, name string) (*fileInfo, error) {
+	if !sdfsValid(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." { // the root has no file info
+		return &fileInfo{info: FileInfo{Name: ".", Attributes: ATTR_DIR}}, nil
+	}
+
+	sdfs.mu.Lock()
+	info, err := sdfs.n8.GetFileInfo(name)
+	sdfs.mu.Unlock()
+	if err != nil {
+		return nil, sdfsError(op, name, err)
+	}
+
+	return &fileInfo{info: *info}, nil
+}
+
+// read fills buf from offset in the file at sdPath.
+//
+// The N8 has one open file, so the file is opened and closed again
+// around each read.
+func (sdfs *SDFS) read(sdPath string, offset uint32, buf []uint8) error {
+	sdfs.mu.Lock()
+	defer sdfs.mu.Unlock()
+
+	file, err := sdfs.n8.Open(sdPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek((int64)(offset), io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// sdfsValid reports whether name is valid for Open. The N8 also treats
+// backslashes as separators, so they are not allowed in names.
+func sdfsValid(name string) bool {
+	return fs.ValidPath(name) && !strings.Contains(name, "\\")
+}
+
+// sdfsPath converts an `fs.FS` name to an SD path.
+func sdfsPath(name string) string {
+	if name == "." {
+		return ""
+	}
+
+	return name
+}
+
+var errIsDir = errors.New("is a directory")
+
+// sdfsError wraps err in an `fs.PathError`, a missing file or path
+// becomes `fs.ErrNotExist`.
+func sdfsError(op string, name string, err error) error {
+	if IsNotFound(err) {
+		err = fs.ErrNotExist
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+//
+// Files
+//
+
+// fileInfo is a `FileInfo` as an `fs.FileInfo`.
+type fileInfo struct {
+	info FileInfo
+}
+
+func (fi *fileInfo) Name() string       { return fi.info.Name }
+func (fi *fileInfo) Size() int64        { return (int64)(fi.info.Size) }
+func (fi *fileInfo) ModTime() time.Time { return fi.info.ModTime() }
+func (fi *fileInfo) IsDir() bool        { return fi.info.IsDir() }
+
+// Sys returns the underlying *FileInfo.
+func (fi *fileInfo) Sys() any { return &fi.info }
+
+// Mode maps the FAT attributes to permissions, read only entries are
+// not writable.
+func (fi *fileInfo) Mode() fs.FileMode {
+	mode := fs.FileMode(0644)
+	if fi.info.IsDir() {
+		mode = fs.ModeDir | 0755
+	}
+	if fi.info.Attributes.ReadOnly() {
+		mode &^= 0222
+	}
+
+	return mode
+}
+
+// sdFile is a file opened by SDFS.Open.
+type sdFile struct {
+	sdfs   *SDFS
+	path   string
+	info   *fileInfo
+	offset uint32
+	closed bool
+}
+
+func (f *sdFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *sdFile) Read(buf []uint8) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrClosed}
+	}
+	if f.offset >= f.info.info.Size {
+		return 0, io.EOF
+	}
+
+	n := (uint32)(min(len(buf), (int)(STREAM_CHUNK_SIZE)))
+	n = min(n, f.info.info.Size-f.offset)
+	if err := f.sdfs.read(f.path, f.offset, buf[:n]); err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: err}
+	}
+	f.offset += n
+
+	return (int)(n), nil
+}
+
+func (f *sdFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.path, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	return nil
+}
+
+// sdDir is a directory opened by SDFS.Open, its entries are read on the
+// first call to ReadDir.
+type sdDir struct {
+	sdfs    *SDFS
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	loaded  bool
+	closed  bool
+}
+
+func (d *sdDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *sdDir) Read([]uint8) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+// ReadDir implements `fs.ReadDirFile`.
+func (d *sdDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+	if !d.loaded {
+		entries, err := d.sdfs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.loaded = true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+func (d *sdDir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package n8_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestSDFS(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	files := map[string][]uint8{
+		"EDN8/MAPROUT.BIN":        testData(0x1000),
+		"games/Game.nes":          testData(0x6010),
+		"games/homebrew/demo.nes": testData(0x100),
+		"empty.txt":               {},
+	}
+	for name, data := range files {
+		if err := device.WriteFile(name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sdfs := n8.NewSDFS(dev)
+	if err := fstest.TestFS(sdfs, "EDN8/MAPROUT.BIN", "games/Game.nes", "games/homebrew/demo.nes", "empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		got, err := fs.ReadFile(sdfs, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s does not match", name)
+		}
+	}
+
+	if _, err := sdfs.Open("games/missing.nes"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open of a missing file = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := sdfs.Open("missing/game.nes"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open in a missing folder = %v, want fs.ErrNotExist", err)
+	}
+}
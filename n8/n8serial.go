@@ -1,15 +1,16 @@
 package n8
 
 import (
+	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"time"
-
-	"github.com/tarm/serial"
 )
 
 const ACK_BLOCK_SIZE uint32 = 0x0400
+const RX_BUFFER_SIZE int = 0x10000
 
 const (
 	ADDR_CFG      uint32 = 0x01800000
@@ -77,6 +78,66 @@ const (
 	CMD_RUN_APP        uint8 = 0xF1
 )
 
+var commandNames = map[uint8]string{
+	CMD_EXEC:           "CMD_EXEC",
+	CMD_STATUS:         "CMD_STATUS",
+	CMD_GET_MODE:       "CMD_GET_MODE",
+	CMD_HARD_RESET:     "CMD_HARD_RESET",
+	CMD_GET_VDC:        "CMD_GET_VDC",
+	CMD_RTC_GET:        "CMD_RTC_GET",
+	CMD_RTC_SET:        "CMD_RTC_SET",
+	CMD_FLA_RD:         "CMD_FLA_RD",
+	CMD_FLA_WR:         "CMD_FLA_WR",
+	CMD_FLA_WR_SDC:     "CMD_FLA_WR_SDC",
+	CMD_MEM_RD:         "CMD_MEM_RD",
+	CMD_MEM_WR:         "CMD_MEM_WR",
+	CMD_MEM_SET:        "CMD_MEM_SET",
+	CMD_MEM_TST:        "CMD_MEM_TST",
+	CMD_MEM_CRC:        "CMD_MEM_CRC",
+	CMD_FPGA_USB:       "CMD_FPGA_USB",
+	CMD_FPGA_SDC:       "CMD_FPGA_SDC",
+	CMD_FPGA_FLA:       "CMD_FPGA_FLA",
+	CMD_FPGA_CFG:       "CMD_FPGA_CFG",
+	CMD_USB_WR:         "CMD_USB_WR",
+	CMD_FIFO_WR:        "CMD_FIFO_WR",
+	CMD_UART_WR:        "CMD_UART_WR",
+	CMD_REINIT:         "CMD_REINIT",
+	CMD_SYS_INF:        "CMD_SYS_INF",
+	CMD_GAME_CTR:       "CMD_GAME_CTR",
+	CMD_UPD_EXEC:       "CMD_UPD_EXEC",
+	CMD_DISK_INIT:      "CMD_DISK_INIT",
+	CMD_DISK_READ:      "CMD_DISK_READ",
+	CMD_DISK_WRITE:     "CMD_DISK_WRITE",
+	CMD_FILE_DIR_OPEN:  "CMD_FILE_DIR_OPEN",
+	CMD_FILE_DIR_READ:  "CMD_FILE_DIR_READ",
+	CMD_FILE_DIR_LD:    "CMD_FILE_DIR_LD",
+	CMD_FILE_DIR_SIZE:  "CMD_FILE_DIR_SIZE",
+	CMD_FILE_DIR_PATH:  "CMD_FILE_DIR_PATH",
+	CMD_FILE_DIR_GET:   "CMD_FILE_DIR_GET",
+	CMD_FILE_OPEN:      "CMD_FILE_OPEN",
+	CMD_FILE_READ:      "CMD_FILE_READ",
+	CMD_FILE_READ_MEM:  "CMD_FILE_READ_MEM",
+	CMD_FILE_WRITE:     "CMD_FILE_WRITE",
+	CMD_FILE_WRITE_MEM: "CMD_FILE_WRITE_MEM",
+	CMD_FILE_CLOSE:     "CMD_FILE_CLOSE",
+	CMD_FILE_PTR:       "CMD_FILE_PTR",
+	CMD_FILE_INFO:      "CMD_FILE_INFO",
+	CMD_FILE_CRC:       "CMD_FILE_CRC",
+	CMD_FILE_DIR_MK:    "CMD_FILE_DIR_MK",
+	CMD_FILE_DEL:       "CMD_FILE_DEL",
+	CMD_USB_RECOV:      "CMD_USB_RECOV",
+	CMD_RUN_APP:        "CMD_RUN_APP",
+}
+
+// CommandName returns the name of a serial command, eg. "CMD_STATUS".
+func CommandName(cmd uint8) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("CMD_%02X", cmd)
+}
+
 //
 // General Serial
 //
@@ -85,34 +146,42 @@ const (
 //
 // Waits 100ms after closing to avoid issues trying to
 // reconnect to quickly.
-func (n8 *N8) CloseSerial() {
-	n8.Port.Close()
+func (n8 *N8) CloseSerial() error {
+	err := n8.Port.Close()
 	time.Sleep(time.Millisecond * 100)
+
+	return err
 }
 
 // InitSerial configures and opens serial connection.
 //
 // Waits 100ms after opening to avoid issues trying to
 // reconnect to quickly.
-func (n8 *N8) InitSerial(device string, timeout time.Duration) {
+func (n8 *N8) InitSerial(device string, timeout time.Duration) error {
 	n8.Address = device
 
-	config := &serial.Config{
-		Name:        n8.Address,
-		Baud:        9600,
-		Size:        8,
-		Parity:      serial.ParityNone,
-		StopBits:    serial.Stop1,
-		ReadTimeout: timeout,
+	port, err := OpenSerial(n8.Address, n8.readTimeout(timeout))
+	if err != nil {
+		return fmt.Errorf("[InitSerial] failed to open serial port: %w", err)
 	}
+	n8.Port = port
 
-	var err error
-	n8.Port, err = serial.OpenPort(config)
-	if err != nil {
-		log.Fatalf("Failed to open serial port: %v", err)
+	time.Sleep(time.Millisecond * 100)
+	return nil
+}
+
+// ReopenSerial reopens the current transport with a new read timeout.
+//
+// Waits 100ms after opening to avoid issues trying to
+// reconnect to quickly.
+func (n8 *N8) ReopenSerial(timeout time.Duration) error {
+	n8.rx = nil
+	if err := n8.Port.Reopen(n8.readTimeout(timeout)); err != nil {
+		return fmt.Errorf("[ReopenSerial] failed to reopen %s: %w", n8.Address, err)
 	}
 
 	time.Sleep(time.Millisecond * 100)
+	return nil
 }
 
 //
@@ -120,106 +189,137 @@ func (n8 *N8) InitSerial(device string, timeout time.Duration) {
 //
 
 // TxData sends an arbitrary stream of data to the N8.
-func (n8 *N8) TxData(buf []uint8) {
-	_, err := n8.Port.Write(buf)
-	if err != nil {
-		log.Fatalf("Failed to write to serial port: %v", err)
+func (n8 *N8) TxData(buf []uint8) error {
+	if err := n8.write("TxData", buf); err != nil {
+		return fmt.Errorf("[TxData] failed to write to serial port: %w", err)
 	}
+
+	return nil
 }
 
 // Tx8 sends 8 bits to the N8.
-func (n8 *N8) Tx8(arg uint8) {
+func (n8 *N8) Tx8(arg uint8) error {
 	var buf []uint8 = make([]uint8, 1)
 	buf[0] = (uint8)(arg)
-	n8.TxData(buf)
+	return n8.TxData(buf)
 }
 
 // Tx8 sends 16 bits to the N8.
-func (n8 *N8) Tx16(arg uint16) {
+func (n8 *N8) Tx16(arg uint16) error {
 	var buf []uint8 = make([]uint8, 2)
 	binary.LittleEndian.PutUint16(buf[:], arg)
 
-	n8.TxData(buf)
+	return n8.TxData(buf)
 }
 
 // Tx32 sends 32 bits to the N8.
-func (n8 *N8) Tx32(arg uint32) {
+func (n8 *N8) Tx32(arg uint32) error {
 	var buf []uint8 = make([]uint8, 4)
 	binary.LittleEndian.PutUint32(buf[:], arg)
 
-	n8.TxData(buf)
+	return n8.TxData(buf)
 }
 
 // TxCmd sends a serial command to the N8.
 //
 // `n8.TxCmdExec()` is generally called after this to execute the command.
-func (n8 *N8) TxCmd(command uint8) {
+func (n8 *N8) TxCmd(command uint8) error {
 	cmd := make([]uint8, 4)
 	cmd[0] = uint8('+')
 	cmd[1] = uint8('+' ^ 0xff)
 	cmd[2] = command
 	cmd[3] = uint8(command ^ 0xff)
 
-	_, err := n8.Port.Write(cmd)
-	if err != nil {
-		log.Fatalf("Failed to write to serial port: %v", err)
+	n8.cmd = command
+	n8.resetDeadline()
+	if err := n8.checkContext(); err != nil {
+		return err
+	}
+
+	if err := n8.write("TxCmd", cmd); err != nil {
+		return fmt.Errorf("[TxCmd] failed to write to serial port: %w", err)
 	}
+
+	return nil
 }
 
 // TxCmdExec sends an `EXEC` command to the N8.
 //
 // This is generally used after `n8.TxCmd()`.
-func (n8 *N8) TxCmdExec() {
-	n8.Tx8(CMD_EXEC)
+func (n8 *N8) TxCmdExec() error {
+	return n8.Tx8(CMD_EXEC)
 }
 
 // TxString sends string data to the N8.
 //
 // First, two bytes are sent indicating the length of the
 // string in bytes. Next, the string itself is transmited.
-func (n8 *N8) TxString(str string) {
-	n8.Tx16((uint16)(len(str)))
-	n8.TxData(([]uint8)(str))
+func (n8 *N8) TxString(str string) error {
+	if err := n8.Tx16((uint16)(len(str))); err != nil {
+		return err
+	}
+	return n8.TxData(([]uint8)(str))
 }
 
 // TxStringFifo sends string data to the N8 FIFO.
 //
 // First, two bytes are sent indicating the length of the
 // string in bytes. Next, the string itself is transmited.
-func (n8 *N8) TxStringFifo(str string) {
+func (n8 *N8) TxStringFifo(str string) error {
 	data := []byte(str)
 	dataLength := make([]byte, 2)
 
 	binary.LittleEndian.PutUint16(dataLength, uint16(len(data)))
 
-	n8.FifoWr(dataLength, 2)
-	n8.FifoWr(data, (uint32)(len(data)))
+	if err := n8.FifoWr(dataLength, 2); err != nil {
+		return err
+	}
+	return n8.FifoWr(data, (uint32)(len(data)))
 }
 
 // TxDataACK sends data in blocks with acks for each one.
 //
 // Sends data in blocks up to 1024 bytes long, checking the N8 status
 // after each block is transmitted.
-func (n8 *N8) TxDataACK(buf []uint8, length uint32) {
+func (n8 *N8) TxDataACK(buf []uint8, length uint32) error {
+	return n8.txDataACK("TxDataACK", buf, length)
+}
+
+// txDataACK is TxDataACK, reporting progress under the name op.
+func (n8 *N8) txDataACK(op string, buf []uint8, length uint32) error {
+	progress := n8.startTransfer(op, length)
 	var offset uint32 = 0
 	var block uint32 = ACK_BLOCK_SIZE
 
+	n8.pending = length
 	for length > 0 {
 		if block > length {
 			block = length
 		}
+		if err := n8.checkContext(); err != nil {
+			return err
+		}
 
-		resp := n8.Rx8()
+		resp, err := n8.Rx8()
+		if err != nil {
+			return err
+		}
 		if resp != 0 {
-
-			log.Fatalf("[TxDataACK] bad ack: %02x", resp)
+			n8.pending = 0 // the N8 gives up on the write after a bad ack
+			return fmt.Errorf("[TxDataACK] bad ack: %02x", resp)
 		}
 
-		n8.TxData(buf[offset : offset+block])
+		if err := n8.TxData(buf[offset : offset+block]); err != nil {
+			return err
+		}
 
 		length -= block
 		offset += block
+		n8.pending = length
+		progress.add(block)
 	}
+
+	return nil
 }
 
 //
@@ -228,56 +328,169 @@ func (n8 *N8) TxDataACK(buf []uint8, length uint32) {
 
 // RxData reads data from the serial port into the provided buffer.
 //
-// It reads one uint8 at a time as reading too quickly causes issues.
-func (n8 *N8) RxData(buf []uint8) {
-	for remaining := len(buf); remaining > 0; {
-		tinyBuf := make([]uint8, 1)
+// Reads through a large buffer, taking as much data as the transport has
+// available on each read. Returns `ErrTimeout` if the transport read
+// timeout passes before the buffer is full, or `ErrOpTimeout` if the
+// operation has also run past `OpTimeout`.
+func (n8 *N8) RxData(buf []uint8) error {
+	start := time.Now()
+	rx := n8.rxReader()
+
+	for got := 0; got < len(buf); {
+		if err := n8.checkContext(); err != nil {
+			n8.trace(start, TRACE_RX, "RxData", buf[:got], err)
+			return err
+		}
+
+		n, err := rx.Read(buf[got:])
+		got += n
+		if n > 0 {
+			continue
+		}
+
+		n8.interrupted = true
+		if err := n8.checkContext(); err != nil {
+			n8.traceTimeout(start, "RxData", buf[:got], err)
+			return err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("[RxData] failed to read from serial port: %w", err)
+			n8.traceTimeout(start, "RxData", buf[:got], err)
+			return err
+		}
+
+		err = fmt.Errorf("[RxData] %w after %d of %d bytes", ErrTimeout, got, len(buf))
+		n8.traceTimeout(start, "RxData", buf[:got], err)
+		n8.autoResync()
+		return err
+	}
+
+	n8.trace(start, TRACE_RX, "RxData", buf, nil)
+	return nil
+}
+
+// rxReader returns the receive buffer for the current transport.
+//
+// The buffer is recreated, dropping anything left in it, whenever the
+// transport changes.
+func (n8 *N8) rxReader() *bufio.Reader {
+	if n8.rx == nil || n8.rxPort != n8.Port {
+		n8.rx = bufio.NewReaderSize(n8.Port, RX_BUFFER_SIZE)
+		n8.rxPort = n8.Port
+	}
 
-		n8.Port.Read(tinyBuf)
-		copy(buf[len(buf)-remaining:], tinyBuf)
+	return n8.rx
+}
 
-		remaining--
+// drain discards any data waiting to be read from the N8.
+//
+// Reads until the transport times out, so the next command starts on
+// a clean link.
+func (n8 *N8) drain() {
+	start := time.Now()
+	rx := n8.rxReader()
+	buffered, _ := rx.Peek(rx.Buffered())
+	drained := append([]uint8{}, buffered...)
+	rx.Discard(len(buffered))
+
+	buf := make([]uint8, RX_BUFFER_SIZE)
+	for {
+		n, err := n8.Port.Read(buf)
+		drained = append(drained, buf[:n]...)
+		if n == 0 || err != nil {
+			break
+		}
 	}
+	n8.trace(start, TRACE_RX, "Drain", drained, nil)
 }
 
 // Rx8 reads 8 bits from the N8.
-func (n8 *N8) Rx8() uint8 {
+func (n8 *N8) Rx8() (uint8, error) {
 	buf := make([]uint8, 1)
-	n8.RxData(buf)
+	if err := n8.RxData(buf); err != nil {
+		return 0, err
+	}
 
-	return (uint8)(buf[0])
+	return (uint8)(buf[0]), nil
 }
 
 // Rx16 reads 16 bits from the N8.
-func (n8 *N8) Rx16() uint16 {
+func (n8 *N8) Rx16() (uint16, error) {
 	buf := make([]uint8, 2)
-	n8.RxData(buf)
+	if err := n8.RxData(buf); err != nil {
+		return 0, err
+	}
 
-	return binary.LittleEndian.Uint16(buf)
+	return binary.LittleEndian.Uint16(buf), nil
 }
 
 // Rx32 reads 32 bits from the N8.
-func (n8 *N8) Rx32() uint32 {
+func (n8 *N8) Rx32() (uint32, error) {
 	buf := make([]uint8, 4)
-	n8.RxData(buf)
+	if err := n8.RxData(buf); err != nil {
+		return 0, err
+	}
 
-	return binary.LittleEndian.Uint32(buf)
+	return binary.LittleEndian.Uint32(buf), nil
 }
 
 // RxString reads string data from the N8.
 //
 // First, two bytes are received indicating the length of the
 // string in bytes. Next, the string itself is read.
-func (n8 *N8) RxString() string {
-	len := n8.Rx16()
+func (n8 *N8) RxString() (string, error) {
+	len, err := n8.Rx16()
+	if err != nil {
+		return "", err
+	}
+
 	buf := make([]uint8, len)
-	n8.RxData(buf)
-	return string(buf)
+	if err := n8.RxData(buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
 }
 
 // RxFileInfo reads serialized FileInfo data from the N8.
-func (n8 *N8) RxFileInfo() (uint32, uint16, uint16, uint8, string) {
-	return n8.Rx32(), n8.Rx16(), n8.Rx16(), n8.Rx8(), n8.RxString()
+func (n8 *N8) RxFileInfo() (*FileInfo, error) {
+	var fileInfo FileInfo
+	var err error
+
+	if fileInfo.Size, err = n8.Rx32(); err != nil {
+		return nil, err
+	}
+	if fileInfo.Date, err = n8.Rx16(); err != nil {
+		return nil, err
+	}
+	if fileInfo.Time, err = n8.Rx16(); err != nil {
+		return nil, err
+	}
+	attributes, err := n8.Rx8()
+	if err != nil {
+		return nil, err
+	}
+	fileInfo.Attributes = (Attr)(attributes)
+	if fileInfo.Name, err = n8.RxString(); err != nil {
+		return nil, err
+	}
+
+	return &fileInfo, nil
+}
+
+// rxResp reads a one byte response code from the N8.
+//
+// A non-zero response is returned as a `StatusError` for the given
+// operation and command.
+func (n8 *N8) rxResp(op string, cmd uint8) error {
+	resp, err := n8.Rx8()
+	if err != nil {
+		return err
+	}
+	if resp != 0 {
+		return &StatusError{Op: op, Cmd: cmd, Status: (uint16)(resp)}
+	}
+
+	return nil
 }
 
 //
@@ -288,25 +501,50 @@ func (n8 *N8) RxFileInfo() (uint32, uint16, uint16, uint8, string) {
 //
 // The high nibble should be 0xa5 if the status code was received
 // successfully. The low nibble indicates the status.
-func (n8 *N8) GetStatus() (bool, uint16) {
-	n8.TxCmd(CMD_STATUS)
-	resp := n8.Rx16()
+func (n8 *N8) GetStatus() (bool, uint16, error) {
+	if err := n8.TxCmd(CMD_STATUS); err != nil {
+		return false, 0, err
+	}
+	resp, err := n8.Rx16()
+	if err != nil {
+		return false, 0, err
+	}
 
 	if (resp & 0xff00) != 0xa500 { // high nibble should be a5
-		return false, resp
+		return false, resp, nil
 	}
 
-	return true, resp & 0x00ff // low nibble returned as status code
+	return true, resp & 0x00ff, nil // low nibble returned as status code
 }
 
 // IsStatusOkay checks status code returned by the N8.
 //
 // A code of `0` is Ok. Other codes indicate specific errors.
-func (n8 *N8) IsStatusOkay() (bool, uint16) {
-	ok, resp := n8.GetStatus()
+func (n8 *N8) IsStatusOkay() (bool, uint16, error) {
+	ok, resp, err := n8.GetStatus()
+	if err != nil {
+		return false, resp, err
+	}
+	if !ok {
+		n8.autoResync()
+		return false, resp, fmt.Errorf("[IsStatusOkay] could not read status: %04x", resp)
+	}
+
+	return resp == 0, resp, nil
+}
+
+// checkStatus reads the N8 status after a command.
+//
+// A non-zero status is returned as a `StatusError` for the given
+// operation and command.
+func (n8 *N8) checkStatus(op string, cmd uint8) error {
+	ok, resp, err := n8.IsStatusOkay()
+	if err != nil {
+		return err
+	}
 	if !ok {
-		fmt.Printf("[isStatusOkay] could not read status: %04x\n", resp)
+		return &StatusError{Op: op, Cmd: cmd, Status: resp}
 	}
 
-	return resp == 0, resp
+	return nil
 }
@@ -0,0 +1,77 @@
+package n8_test
+
+import (
+	"bytes"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/emulator"
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+const BENCH_SIZE = 0x10000
+
+// byteTransport limits every read to a single byte, like the original
+// receive path.
+type byteTransport struct {
+	n8.Transport
+}
+
+func (t *byteTransport) Read(buf []uint8) (int, error) {
+	return t.Transport.Read(buf[:min(len(buf), 1)])
+}
+
+// benchRead runs read against an emulated N8 holding BENCH_SIZE bytes in
+// memory and in BENCH.BIN, once the way goedlink used to read (one byte
+// per serial read, 32 bytes per memory read) and once with bulk reads.
+func benchRead(b *testing.B, read func(*n8.N8, []uint8) error) {
+	data := testData(BENCH_SIZE)
+	device := emulator.New()
+	copy(device.Memory, data)
+	if err := device.WriteFile("BENCH.BIN", data); err != nil {
+		b.Fatal(err)
+	}
+	transport := emulator.NewTransport(device)
+	b.Cleanup(transport.Shutdown)
+
+	legacy := n8.NewN8(&byteTransport{transport})
+	legacy.ChunkSize = 0x20
+
+	for _, run := range []struct {
+		name string
+		n8   *n8.N8
+	}{
+		{"byte-at-a-time", legacy},
+		{"bulk", n8.NewN8(transport)},
+	} {
+		b.Run(run.name, func(b *testing.B) {
+			buf := make([]uint8, len(data))
+			b.SetBytes(BENCH_SIZE)
+			for i := 0; i < b.N; i++ {
+				if err := read(run.n8, buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if !bytes.Equal(buf, data) {
+				b.Fatal("data read back does not match")
+			}
+		})
+	}
+}
+
+func BenchmarkReadMemory(b *testing.B) {
+	benchRead(b, func(dev *n8.N8, buf []uint8) error {
+		return dev.ReadMemory(0, buf, (uint32)(len(buf)))
+	})
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	benchRead(b, func(dev *n8.N8, buf []uint8) error {
+		if err := dev.OpenFile("BENCH.BIN", n8.FAT_READ); err != nil {
+			return err
+		}
+		if err := dev.ReadFile(buf, (uint32)(len(buf))); err != nil {
+			return err
+		}
+		return dev.CloseFile()
+	})
+}
@@ -0,0 +1,225 @@
+package n8
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+)
+
+const STREAM_CHUNK_SIZE uint32 = 0x100000
+
+// WriteFileFrom writes everything read from r to a file on the SD card,
+// replacing it if it exists.
+//
+// The data is sent STREAM_CHUNK_SIZE bytes at a time, so r can be a pipe
+// or a file larger than memory. Returns the number of bytes written.
+func (n8 *N8) WriteFileFrom(sdPath string, r io.Reader) (int64, error) {
+	return n8.writeFileFrom(sdPath, 0, r)
+}
+
+// writeFileFrom is WriteFileFrom starting at offset in the file. If
+// offset is not 0 the file must already be at least that long, and
+// anything before offset is kept.
+func (n8 *N8) writeFileFrom(sdPath string, offset uint32, r io.Reader) (int64, error) {
+	mode := FAT_CREATE_ALWAYS | FAT_WRITE
+	if offset > 0 {
+		mode = FAT_OPEN_APPEND | FAT_WRITE
+	}
+	file, err := n8.openSDFile(sdPath, mode)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek((int64)(offset), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n8.stream = n8.startTransfer("FileWrite", streamSize(r))
+	defer func() { n8.stream = nil }()
+
+	buf := make([]uint8, STREAM_CHUNK_SIZE)
+	var written int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += (int64)(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return written, fmt.Errorf("[WriteFileFrom] error reading source: %w", err)
+		}
+	}
+	n8.stream.end()
+
+	return written, file.Close()
+}
+
+// ReadFileTo writes the contents of a file on the SD card to w.
+//
+// The file is read STREAM_CHUNK_SIZE bytes at a time. Returns the number
+// of bytes written to w.
+func (n8 *N8) ReadFileTo(sdPath string, w io.Writer) (int64, error) {
+	return n8.readFileTo(sdPath, 0, w)
+}
+
+// readFileTo is ReadFileTo starting at offset in the file.
+func (n8 *N8) readFileTo(sdPath string, offset uint32, w io.Writer) (int64, error) {
+	file, err := n8.Open(sdPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek((int64)(offset), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	remaining := (uint32)(max(file.Size()-(int64)(offset), 0))
+	n8.stream = n8.startTransfer("ReadFile", remaining)
+	defer func() { n8.stream = nil }()
+
+	buf := make([]uint8, min(remaining, STREAM_CHUNK_SIZE))
+	var read int64
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return read, fmt.Errorf("[ReadFileTo] error writing destination: %w", err)
+			}
+			read += (int64)(n)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return read, err
+		}
+	}
+
+	return read, file.Close()
+}
+
+// DiskReadTo writes count sectors of the SD card, starting at sector
+// address, to w.
+//
+// The sectors are read STREAM_CHUNK_SIZE bytes at a time. Returns the
+// number of bytes written to w.
+func (n8 *N8) DiskReadTo(w io.Writer, address uint32, count uint32) (int64, error) {
+	const chunkSectors = STREAM_CHUNK_SIZE / SECTOR_SIZE
+
+	total := (uint64)(count) * (uint64)(SECTOR_SIZE)
+	n8.stream = n8.startTransfer("DiskRead", (uint32)(min(total, math.MaxUint32)))
+	defer func() { n8.stream = nil }()
+
+	buf := make([]uint8, min(count, chunkSectors)*SECTOR_SIZE)
+	var written int64
+	for count > 0 {
+		sectors := min(count, chunkSectors)
+		chunk := buf[:sectors*SECTOR_SIZE]
+		if err := n8.DiskRead(chunk, address, sectors); err != nil {
+			return written, err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return written, fmt.Errorf("[DiskReadTo] error writing destination: %w", err)
+		}
+
+		written += (int64)(len(chunk))
+		address += sectors
+		count -= sectors
+	}
+
+	return written, nil
+}
+
+// copyWithinSD copies a file of size bytes to another path on the SD
+// card.
+//
+// The N8 only has one file open at a time, so each STREAM_CHUNK_SIZE
+// chunk is read with the source open, then written with the destination
+// open. Progress counts both, every byte crosses the link twice.
+func (n8 *N8) copyWithinSD(source string, destination string, size uint32) error {
+	total := (uint64)(size) * 2
+	n8.stream = n8.startTransfer("CopyFile", (uint32)(min(total, math.MaxUint32)))
+	defer func() { n8.stream = nil }()
+
+	buf := make([]uint8, min(size, STREAM_CHUNK_SIZE))
+	mode := FAT_CREATE_ALWAYS | FAT_WRITE
+	for offset := (uint32)(0); ; {
+		chunk := buf[:min(size-offset, (uint32)(len(buf)))]
+		if err := n8.transferChunk(source, FAT_READ, offset, chunk); err != nil {
+			return err
+		}
+		if err := n8.transferChunk(destination, mode, offset, chunk); err != nil {
+			return err
+		}
+
+		offset += (uint32)(len(chunk))
+		if offset >= size {
+			return nil
+		}
+		mode = FAT_OPEN_EXISTING | FAT_WRITE
+	}
+}
+
+// sameSDPath reports whether a and b name the same path on the SD card,
+// which is not case sensitive.
+func sameSDPath(a string, b string) bool {
+	return strings.EqualFold(path.Clean("/"+a), path.Clean("/"+b))
+}
+
+// transferChunk opens sdPath with mode, then writes buf at offset if the
+// mode allows writing or fills buf from offset if not, and closes the
+// file again.
+func (n8 *N8) transferChunk(sdPath string, mode uint8, offset uint32, buf []uint8) error {
+	file, err := n8.openSDFile(sdPath, mode)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek((int64)(offset), io.SeekStart); err != nil {
+		return err
+	}
+	if mode&FAT_WRITE != 0 {
+		_, err = file.Write(buf)
+	} else {
+		_, err = io.ReadFull(file, buf)
+	}
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// streamSize returns the number of bytes left in r, if it can tell
+// without reading, or 0.
+func streamSize(r io.Reader) uint32 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return (uint32)(min(r.Len(), math.MaxUint32))
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0
+		}
+		return (uint32)(min(info.Size()-offset, math.MaxUint32))
+	}
+
+	return 0
+}
@@ -0,0 +1,99 @@
+package n8_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestStreamFiles(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	// more than one STREAM_CHUNK_SIZE chunk, through a reader with no length
+	data := testData((int)(n8.STREAM_CHUNK_SIZE)*2 + 0x1234)
+	written, err := dev.WriteFileFrom("stream.bin", io.MultiReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != (int64)(len(data)) {
+		t.Errorf("WriteFileFrom wrote %d bytes, want %d", written, len(data))
+	}
+	stored, err := device.ReadFile("stream.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Fatal("WriteFileFrom: SD card file does not match")
+	}
+
+	var got bytes.Buffer
+	if _, err := dev.ReadFileTo("stream.bin", &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), data) {
+		t.Error("ReadFileTo: data does not match")
+	}
+}
+
+func TestCopyWithinSD(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	for _, size := range []int{0, 0x100, (int)(n8.STREAM_CHUNK_SIZE)*2 + 0x10} {
+		data := testData(size)
+		if err := device.WriteFile("a.bin", data); err != nil {
+			t.Fatal(err)
+		}
+		if err := device.WriteFile("b.bin", testData(0x400)); err != nil { // replaced
+			t.Fatal(err)
+		}
+
+		if err := dev.CopyFile("sd:a.bin", "sd:b.bin"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := device.ReadFile("b.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("copy of a %d byte file does not match", size)
+		}
+	}
+}
+
+func TestCopyToItself(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	data := testData((int)(n8.STREAM_CHUNK_SIZE) + 0x10)
+	if err := device.WriteFile("dir/a.bin", data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, destination := range []string{"sd:dir/a.bin", "sd:/DIR/A.BIN", "sd:dir//./a.bin"} {
+		if err := dev.CopyFile("sd:dir/a.bin", destination); err == nil {
+			t.Errorf("copy to %s did not fail", destination)
+		}
+	}
+	got, err := device.ReadFile("dir/a.bin")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("source changed: %v", err)
+	}
+}
+
+func TestDiskReadTo(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	sectors := n8.STREAM_CHUNK_SIZE/n8.SECTOR_SIZE + 3
+	device.Disk = testData((int)((sectors + 8) * n8.SECTOR_SIZE))
+
+	var got bytes.Buffer
+	written, err := dev.DiskReadTo(&got, 8, sectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := device.Disk[8*n8.SECTOR_SIZE:]
+	if written != (int64)(len(want)) || !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("DiskReadTo read %d bytes that do not match", written)
+	}
+}
@@ -0,0 +1,421 @@
+package n8
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncMode selects which side of a sync is copied to the other.
+type SyncMode string
+
+const (
+	SYNC_PUSH   SyncMode = "push"   // copy new and changed files from the host to the SD card
+	SYNC_PULL   SyncMode = "pull"   // copy new and changed files from the SD card to the host
+	SYNC_MIRROR SyncMode = "mirror" // push, then delete what is not on the host from the SD card
+)
+
+// SyncOp is a single kind of step in a sync plan.
+type SyncOp string
+
+const (
+	SYNC_MKDIR      SyncOp = "mkdir"      // create a directory on the SD card
+	SYNC_UPLOAD     SyncOp = "upload"     // copy a file from the host to the SD card
+	SYNC_HOST_MKDIR SyncOp = "host-mkdir" // create a directory on the host
+	SYNC_DOWNLOAD   SyncOp = "download"   // copy a file from the SD card to the host
+	SYNC_DELETE     SyncOp = "delete"     // delete a file or empty directory from the SD card
+)
+
+// SYSTEM_DIR is the N8 system folder, sync never compares or deletes it.
+const SYSTEM_DIR = "EDN8"
+
+// FAT_TIME_RESOLUTION is the granularity of FAT modification times.
+const FAT_TIME_RESOLUTION = time.Second * 2
+
+// SyncAction is one step of a sync plan.
+//
+// `Host` and `SD` are the full paths on each side, `Reason` says why the
+// step is needed, eg. "new" or "content differs".
+type SyncAction struct {
+	Op     SyncOp
+	Host   string
+	SD     string
+	Size   uint32
+	Reason string
+}
+
+func (a SyncAction) String() string {
+	switch a.Op {
+	case SYNC_MKDIR, SYNC_DELETE:
+		return fmt.Sprintf("%-10s sd:%s (%s)", a.Op, a.SD, a.Reason)
+	case SYNC_HOST_MKDIR:
+		return fmt.Sprintf("%-10s %s (%s)", a.Op, a.Host, a.Reason)
+	case SYNC_DOWNLOAD:
+		return fmt.Sprintf("%-10s sd:%s -> %s (%s)", a.Op, a.SD, a.Host, a.Reason)
+	}
+
+	return fmt.Sprintf("%-10s %s -> sd:%s (%s)", a.Op, a.Host, a.SD, a.Reason)
+}
+
+// syncEntry is a file or directory on either side of a sync, keyed by
+// its lower case path relative to the synced directory since FAT names
+// are not case sensitive.
+type syncEntry struct {
+	rel     string
+	dir     bool
+	size    uint32
+	modTime time.Time
+}
+
+// PlanSync compares a host directory with a directory on the SD card
+// and returns the steps that bring them in line for mode.
+//
+// Files are the same if their size matches and either their modification
+// times are within FAT_TIME_RESOLUTION or their CRCs match, the SD CRC is
+// calculated by the N8. Names matching any of the exclude patterns (see
+// `path.Match`) are ignored on both sides, as is the SYSTEM_DIR folder
+// on the SD card.
+//
+// The N8 has no command to set modification times, so an uploaded file
+// carries the time of the upload and later syncs compare it by CRC. That
+// reads the whole file on the N8, though only the CRC crosses the link.
+func (n8 *N8) PlanSync(hostDir string, sdDir string, mode SyncMode, exclude []string) ([]SyncAction, error) {
+	switch mode {
+	case SYNC_PUSH, SYNC_PULL, SYNC_MIRROR:
+	default:
+		return nil, fmt.Errorf("[PlanSync] unknown sync mode %q", mode)
+	}
+
+	sdDir = strings.Trim(sdDir, "/")
+	excluded := func(name string) bool {
+		for _, pattern := range exclude {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	hostEntries, hostExists, err := hostSyncEntries(hostDir, excluded)
+	if err != nil {
+		return nil, err
+	}
+	sdEntries, sdExists, err := n8.sdSyncEntries(sdDir, excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	var mkdirs, copies []SyncAction
+	replaced := map[string]SyncAction{} // entries in the way of a new file or folder
+	deletes := map[string]SyncAction{}  // entries no longer on the host
+	switch {
+	case mode == SYNC_PULL && !sdExists:
+		return nil, fmt.Errorf("[PlanSync] sd:%s does not exist", sdDir)
+	case mode == SYNC_PULL && !hostExists:
+		mkdirs = append(mkdirs, SyncAction{Op: SYNC_HOST_MKDIR, Host: hostDir, Reason: "new"})
+	case mode != SYNC_PULL && !hostExists:
+		return nil, fmt.Errorf("[PlanSync] %s does not exist", hostDir)
+	case mode != SYNC_PULL && !sdExists:
+		mkdirs = append(mkdirs, SyncAction{Op: SYNC_MKDIR, SD: sdDir, Reason: "new"})
+	}
+
+	for key, host := range hostEntries {
+		sd, onSD := sdEntries[key]
+		hostPath := filepath.Join(hostDir, filepath.FromSlash(host.rel))
+		sdPath := path.Join(sdDir, host.rel)
+
+		if onSD && sd.dir != host.dir {
+			if mode == SYNC_PULL {
+				return nil, fmt.Errorf("[PlanSync] %s is a file on one side and a directory on the other", host.rel)
+			}
+			for sdPath, action := range sdDeletes(sdDir, sd, sdEntries, "replaced") {
+				replaced[sdPath] = action
+			}
+			onSD = false
+		}
+
+		switch {
+		case mode == SYNC_PULL:
+			continue // host only entries are left alone
+		case host.dir && !onSD:
+			mkdirs = append(mkdirs, SyncAction{Op: SYNC_MKDIR, SD: sdPath, Reason: "new"})
+		case host.dir:
+		case !onSD:
+			copies = append(copies, SyncAction{Op: SYNC_UPLOAD, Host: hostPath, SD: sdPath, Size: host.size, Reason: "new"})
+		default:
+			reason, err := n8.syncDiff(hostPath, sdPath, host, sd)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				copies = append(copies, SyncAction{Op: SYNC_UPLOAD, Host: hostPath, SD: sdPath, Size: host.size, Reason: reason})
+			}
+		}
+	}
+
+	for key, sd := range sdEntries {
+		host, onHost := hostEntries[key]
+		hostPath := filepath.Join(hostDir, filepath.FromSlash(sd.rel))
+		sdPath := path.Join(sdDir, sd.rel)
+
+		switch {
+		case mode == SYNC_PUSH:
+			continue // SD only entries are left alone
+		case mode == SYNC_MIRROR:
+			if _, ok := replaced[sdPath]; !ok && !onHost {
+				deletes[sdPath] = SyncAction{Op: SYNC_DELETE, SD: sdPath, Size: sd.size, Reason: "not on host"}
+			}
+		case onHost && host.dir != sd.dir:
+			// reported above
+		case sd.dir && !onHost:
+			mkdirs = append(mkdirs, SyncAction{Op: SYNC_HOST_MKDIR, Host: hostPath, Reason: "new"})
+		case sd.dir:
+		case !onHost:
+			copies = append(copies, SyncAction{Op: SYNC_DOWNLOAD, Host: hostPath, SD: sdPath, Size: sd.size, Reason: "new"})
+		default:
+			reason, err := n8.syncDiff(hostPath, sdPath, host, sd)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				copies = append(copies, SyncAction{Op: SYNC_DOWNLOAD, Host: hostPath, SD: sdPath, Size: sd.size, Reason: reason})
+			}
+		}
+	}
+
+	// parents are created before their children and deleted after them,
+	// anything in the way of a new entry is deleted before it is created
+	sort.Slice(mkdirs, func(i, j int) bool { return mkdirs[i].SD+mkdirs[i].Host < mkdirs[j].SD+mkdirs[j].Host })
+	sort.Slice(copies, func(i, j int) bool { return copies[i].SD < copies[j].SD })
+
+	plan := sortedDeletes(replaced)
+	plan = append(plan, mkdirs...)
+	plan = append(plan, copies...)
+	plan = append(plan, sortedDeletes(deletes)...)
+
+	return plan, nil
+}
+
+// sortedDeletes returns the delete steps in deletes, children before
+// their parent directory.
+func sortedDeletes(deletes map[string]SyncAction) []SyncAction {
+	plan := make([]SyncAction, 0, len(deletes))
+	for _, action := range deletes {
+		plan = append(plan, action)
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].SD > plan[j].SD })
+
+	return plan
+}
+
+// ApplySync carries out a plan from PlanSync.
+func (n8 *N8) ApplySync(plan []SyncAction) error {
+	for _, action := range plan {
+		var err error
+		switch action.Op {
+		case SYNC_MKDIR:
+			err = n8.mkdirAll(action.SD)
+		case SYNC_UPLOAD:
+			err = n8.CopyFile(action.Host, "sd:"+action.SD)
+		case SYNC_HOST_MKDIR:
+			err = os.MkdirAll(action.Host, 0755)
+		case SYNC_DOWNLOAD:
+			err = n8.CopyFile("sd:"+action.SD, action.Host)
+		case SYNC_DELETE:
+			err = n8.DeleteRecord(action.SD)
+		default:
+			err = fmt.Errorf("unknown sync step %q", action.Op)
+		}
+		if err != nil {
+			return fmt.Errorf("[ApplySync] %s: %w", action, err)
+		}
+	}
+
+	return nil
+}
+
+// syncDiff returns why two files differ, or "" if they are the same.
+func (n8 *N8) syncDiff(hostPath string, sdPath string, host syncEntry, sd syncEntry) (string, error) {
+	if host.size != sd.size {
+		return "size differs", nil
+	}
+
+	diff := host.modTime.Sub(sd.modTime)
+	if diff < FAT_TIME_RESOLUTION && diff > -FAT_TIME_RESOLUTION {
+		return "", nil
+	}
+
+	hostCrc, err := hostFileCrc(hostPath)
+	if err != nil {
+		return "", err
+	}
+	sdCrc, err := n8.sdFileCrc(sdPath, sd.size)
+	if err != nil {
+		return "", err
+	}
+	if hostCrc != sdCrc {
+		return "content differs", nil
+	}
+
+	return "", nil
+}
+
+// sdDeletes returns the steps deleting an SD entry and everything in
+// it, keyed by SD path.
+func sdDeletes(sdDir string, entry syncEntry, entries map[string]syncEntry, reason string) map[string]SyncAction {
+	deletes := map[string]SyncAction{}
+
+	prefix := strings.ToLower(entry.rel) + "/"
+	for key, child := range entries {
+		if key == strings.ToLower(entry.rel) || strings.HasPrefix(key, prefix) {
+			sdPath := path.Join(sdDir, child.rel)
+			deletes[sdPath] = SyncAction{Op: SYNC_DELETE, SD: sdPath, Size: child.size, Reason: reason}
+		}
+	}
+
+	return deletes
+}
+
+// hostSyncEntries lists everything below dir on the host, a missing dir
+// is reported as not existing rather than as an error.
+func hostSyncEntries(dir string, excluded func(string) bool) (map[string]syncEntry, bool, error) {
+	entries := map[string]syncEntry{}
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return entries, false, nil
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if excluded(d.Name()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		entry := syncEntry{rel: rel, dir: d.IsDir(), modTime: info.ModTime()}
+		if !entry.dir {
+			if info.Size() > 0xFFFFFFFF {
+				return fmt.Errorf("%s is too large for the SD card", p)
+			}
+			entry.size = (uint32)(info.Size())
+		}
+		entries[strings.ToLower(rel)] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("[PlanSync] %w", err)
+	}
+
+	return entries, true, nil
+}
+
+// sdSyncEntries lists everything below dir on the SD card, a missing dir
+// is reported as not existing rather than as an error.
+func (n8 *N8) sdSyncEntries(dir string, excluded func(string) bool) (map[string]syncEntry, bool, error) {
+	entries := map[string]syncEntry{}
+
+	if dir != "" {
+		info, err := n8.GetFileInfo(dir)
+		if IsNotFound(err) {
+			return entries, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if !info.IsDir() {
+			return nil, false, fmt.Errorf("[PlanSync] sd:%s is not a directory", dir)
+		}
+	}
+
+	err := n8.WalkDir(dir, func(p string, info *FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if excluded(info.Name) || (info.IsDir() && strings.EqualFold(p, SYSTEM_DIR)) {
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, dir), "/")
+		entries[strings.ToLower(rel)] = syncEntry{rel: rel, dir: info.IsDir(), size: info.Size, modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return entries, true, nil
+}
+
+// mkdirAll creates a directory on the SD card along with any missing
+// parents.
+func (n8 *N8) mkdirAll(dir string) error {
+	var current string
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		current = path.Join(current, name)
+		if err := n8.mkdir(current); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sdFileCrc returns the CRC of the first size bytes of a file on the SD
+// card, as calculated by the N8.
+func (n8 *N8) sdFileCrc(sdPath string, size uint32) (uint32, error) {
+	file, err := n8.Open(sdPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	crc, err := file.Crc(size)
+	if err != nil {
+		return 0, err
+	}
+
+	return crc, file.Close()
+}
+
+// hostFileCrc returns the CRC of a file on the host, matching FileCrc.
+func hostFileCrc(hostPath string) (uint32, error) {
+	file, err := os.Open(hostPath)
+	if err != nil {
+		return 0, fmt.Errorf("[PlanSync] %w", err)
+	}
+	defer file.Close()
+
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, file); err != nil {
+		return 0, fmt.Errorf("[PlanSync] %w", err)
+	}
+
+	return hash.Sum32(), nil
+}
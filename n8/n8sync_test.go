@@ -0,0 +1,114 @@
+package n8_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+func TestPlanSyncMirror(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+
+	host := t.TempDir()
+	hostFiles := map[string][]uint8{
+		"a/x.nes":  testData(0x100), // a is a file on the SD card
+		"b.nes":    testData(0x200), // b.nes is a folder on the SD card
+		"same.nes": testData(0x300),
+	}
+	for name, data := range hostFiles {
+		path := filepath.Join(host, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sdFiles := map[string][]uint8{
+		"sync/a":           {1},
+		"sync/b.nes/old":   {2},
+		"sync/gone.nes":    {3},
+		"sync/same.nes":    hostFiles["same.nes"],
+		"EDN8/MAPROUT.BIN": {4},
+	}
+	for name, data := range sdFiles {
+		if err := device.WriteFile(name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := dev.PlanSync(host, "sync", n8.SYNC_MIRROR, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []string
+	for _, action := range plan {
+		steps = append(steps, (string)(action.Op)+" "+action.SD+" ("+action.Reason+")")
+	}
+	want := []string{
+		"delete sync/b.nes/old (replaced)",
+		"delete sync/b.nes (replaced)",
+		"delete sync/a (replaced)",
+		"mkdir sync/a (new)",
+		"upload sync/a/x.nes (new)",
+		"upload sync/b.nes (new)",
+		"delete sync/gone.nes (not on host)",
+	}
+	if !slices.Equal(steps, want) {
+		t.Fatalf("PlanSync =\n%q\nwant\n%q", steps, want)
+	}
+
+	if err := dev.ApplySync(plan); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range hostFiles {
+		got, err := device.ReadFile("sync/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("sd:sync/%s does not match the host", name)
+		}
+	}
+	if _, err := dev.GetFileInfo("sync/gone.nes"); !n8.IsNotFound(err) {
+		t.Errorf("sd:sync/gone.nes was not deleted: %v", err)
+	}
+
+	plan, err = dev.PlanSync(host, "sync", n8.SYNC_MIRROR, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 0 {
+		t.Errorf("PlanSync after ApplySync = %v, want nothing to do", plan)
+	}
+}
+
+func TestPlanSyncPull(t *testing.T) {
+	dev, device := newEmulatedN8(t)
+	if err := device.WriteFile("saves/game.srm", testData(0x2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	host := filepath.Join(t.TempDir(), "saves")
+	plan, err := dev.PlanSync(host, "saves", n8.SYNC_PULL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.ApplySync(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(host, "game.srm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, testData(0x2000)) {
+		t.Error("pulled file does not match the SD card")
+	}
+}
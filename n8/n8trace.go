@@ -0,0 +1,93 @@
+package n8
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	TRACE_TX = "tx"
+	TRACE_RX = "rx"
+)
+
+// TraceEvent is a single `TxCmd`, `TxData` or `RxData` call, or the
+// padding and draining done by `Resync`.
+//
+// `Cmd` is the name of the command the call belongs to, so arguments
+// sent with `TxData` and replies read with `RxData` can be told apart.
+// `Start` is the time since the trace began, `Data` is hex encoded.
+// `Timeout` is set when the call ended on a read that returned nothing,
+// so a replay can stop the reply at the same point.
+type TraceEvent struct {
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Dir      string        `json:"dir"`
+	Call     string        `json:"call"`
+	Cmd      string        `json:"cmd"`
+	Data     string        `json:"data"`
+	Error    string        `json:"error,omitempty"`
+	Timeout  bool          `json:"timeout,omitempty"`
+}
+
+// Tracer writes TraceEvents as JSON lines.
+type Tracer struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewTracer returns a Tracer writing to w.
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{enc: json.NewEncoder(w), start: time.Now()}
+}
+
+// record writes an event for a call that started at start.
+//
+// Tracing is best effort, write errors are ignored so they never break
+// the operation being traced.
+func (t *Tracer) record(start time.Time, dir string, call string, cmd uint8, data []uint8, err error, timeout bool) {
+	event := TraceEvent{
+		Start:    start.Sub(t.start),
+		Duration: time.Since(start),
+		Dir:      dir,
+		Call:     call,
+		Cmd:      CommandName(cmd),
+		Data:     hex.EncodeToString(data),
+		Timeout:  timeout,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.enc.Encode(event)
+}
+
+// trace records a call if tracing is enabled.
+func (n8 *N8) trace(start time.Time, dir string, call string, data []uint8, err error) {
+	if n8.Trace != nil {
+		n8.Trace.record(start, dir, call, n8.cmd, data, err, false)
+	}
+}
+
+// traceTimeout records a read that ended because the transport returned
+// nothing.
+func (n8 *N8) traceTimeout(start time.Time, call string, data []uint8, err error) {
+	if n8.Trace != nil {
+		n8.Trace.record(start, TRACE_RX, call, n8.cmd, data, err, true)
+	}
+}
+
+// write writes buf to the transport and traces it as call.
+func (n8 *N8) write(call string, buf []uint8) error {
+	start := time.Now()
+	_, err := n8.Port.Write(buf)
+	n8.trace(start, TRACE_TX, call, buf, err)
+
+	return err
+}
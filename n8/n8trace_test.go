@@ -0,0 +1,82 @@
+package n8_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+	"forge.rights.ninja/jeff/goedlink/nesrom"
+)
+
+// recordSession runs session against the emulator with tracing enabled
+// and returns the trace.
+func recordSession(t *testing.T, session func(dev *n8.N8) error) []uint8 {
+	t.Helper()
+
+	var trace bytes.Buffer
+	dev, _ := newEmulatedN8(t)
+	dev.Trace = n8.NewTracer(&trace)
+	if err := session(dev); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+
+	return trace.Bytes()
+}
+
+// replaySession runs session against a ReplayTransport playing trace.
+func replaySession(t *testing.T, trace []uint8, session func(dev *n8.N8) error) error {
+	t.Helper()
+
+	transport, err := n8.NewReplayTransport(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return session(n8.NewN8(transport))
+}
+
+func TestTraceReplay(t *testing.T) {
+	data := testData(0x12345)
+	var read []uint8
+	session := func(dev *n8.N8) error {
+		if err := dev.WriteMemory(nesrom.ADDR_PRG, data, (uint32)(len(data))); err != nil {
+			return err
+		}
+		read = make([]uint8, len(data))
+		if err := dev.ReadMemory(nesrom.ADDR_PRG, read, (uint32)(len(read))); err != nil {
+			return err
+		}
+		_, err := dev.GetFileInfo("missing.nes")
+		if !n8.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	trace := recordSession(t, session)
+	if !bytes.Equal(read, data) {
+		t.Fatal("recorded read does not match")
+	}
+
+	read = nil
+	if err := replaySession(t, trace, session); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Error("replayed read does not match")
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	trace := recordSession(t, func(dev *n8.N8) error {
+		return dev.WriteMemory(nesrom.ADDR_PRG, testData(0x100), 0x100)
+	})
+
+	err := replaySession(t, trace, func(dev *n8.N8) error {
+		return dev.WriteMemory(nesrom.ADDR_PRG, make([]uint8, 0x100), 0x100)
+	})
+	if err == nil || !strings.Contains(err.Error(), "trace diverged") {
+		t.Errorf("replaying a different write = %v, want trace diverged", err)
+	}
+}
@@ -0,0 +1,95 @@
+package n8
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// Transport is the byte stream the N8 protocol runs over.
+//
+// Reads should return once data is available or the read timeout passed
+// to `Reopen` (or the transport constructor) has elapsed.
+//
+// An N8 never uses its transport from more than one goroutine at a time,
+// so transports need not be safe for concurrent use.
+type Transport interface {
+	Read(buf []uint8) (int, error)
+	Write(buf []uint8) (int, error)
+	Close() error
+
+	// Reopen closes the transport, if open, and opens it again using
+	// the given read timeout.
+	Reopen(timeout time.Duration) error
+}
+
+// SerialTransport is the default Transport, a serial port opened with
+// tarm/serial.
+type SerialTransport struct {
+	Name string
+	port *serial.Port
+}
+
+// OpenSerial opens the named serial device as a Transport.
+func OpenSerial(name string, timeout time.Duration) (*SerialTransport, error) {
+	t := &SerialTransport{Name: name}
+	if err := t.Reopen(timeout); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Read reads from the serial port.
+func (t *SerialTransport) Read(buf []uint8) (int, error) {
+	if t.port == nil {
+		return 0, fmt.Errorf("serial port %s is closed", t.Name)
+	}
+
+	return t.port.Read(buf)
+}
+
+// Write writes to the serial port.
+func (t *SerialTransport) Write(buf []uint8) (int, error) {
+	if t.port == nil {
+		return 0, fmt.Errorf("serial port %s is closed", t.Name)
+	}
+
+	return t.port.Write(buf)
+}
+
+// Close closes the serial port. Closing an already closed port is a no-op.
+func (t *SerialTransport) Close() error {
+	if t.port == nil {
+		return nil
+	}
+
+	err := t.port.Close()
+	t.port = nil
+
+	return err
+}
+
+// Reopen closes the serial port, if open, and opens it again with the
+// given read timeout.
+func (t *SerialTransport) Reopen(timeout time.Duration) error {
+	t.Close()
+
+	config := &serial.Config{
+		Name:        t.Name,
+		Baud:        9600,
+		Size:        8,
+		Parity:      serial.ParityNone,
+		StopBits:    serial.Stop1,
+		ReadTimeout: timeout,
+	}
+
+	port, err := serial.OpenPort(config)
+	if err != nil {
+		return err
+	}
+	t.port = port
+
+	return nil
+}
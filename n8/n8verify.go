@@ -0,0 +1,116 @@
+package n8
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+const VERIFY_ATTEMPTS = 3
+
+// writeChunk writes buf to the open file, which must be at offset.
+//
+// If `Verify` is set the N8 calculates the CRC of the chunk and the
+// write is retried until it matches, leaving the file pointer after the
+// chunk either way.
+func (n8 *N8) writeChunk(sdPath string, offset uint32, buf []uint8) error {
+	length := (uint32)(len(buf))
+
+	for attempt := 1; ; attempt++ {
+		if err := n8.FileWrite(buf, length); err != nil {
+			return err
+		}
+		if !n8.Verify {
+			return nil
+		}
+
+		if err := n8.FileSetPointer(offset); err != nil {
+			return err
+		}
+		got, err := n8.FileCrc(length)
+		if err != nil {
+			return err
+		}
+		want := crc32.ChecksumIEEE(buf)
+		if got == want {
+			return nil
+		}
+		if attempt == VERIFY_ATTEMPTS {
+			return verifyError(sdPath, offset, got, want, attempt)
+		}
+
+		if err := n8.FileSetPointer(offset); err != nil {
+			return err
+		}
+		n8.stream.undo(length)
+	}
+}
+
+// readChunk fills buf from the open file, which must be at offset.
+//
+// If `Verify` is set the data is checked against a CRC calculated by the
+// N8 and the read is retried until they match.
+func (n8 *N8) readChunk(sdPath string, offset uint32, buf []uint8) error {
+	length := (uint32)(len(buf))
+
+	for attempt := 1; ; attempt++ {
+		if err := n8.ReadFile(buf, length); err != nil {
+			return err
+		}
+		if !n8.Verify {
+			return nil
+		}
+
+		if err := n8.FileSetPointer(offset); err != nil {
+			return err
+		}
+		want, err := n8.FileCrc(length)
+		if err != nil {
+			return err
+		}
+		got := crc32.ChecksumIEEE(buf)
+		if got == want {
+			return nil
+		}
+		if attempt == VERIFY_ATTEMPTS {
+			return verifyError(sdPath, offset, got, want, attempt)
+		}
+
+		if err := n8.FileSetPointer(offset); err != nil {
+			return err
+		}
+		n8.stream.undo(length)
+	}
+}
+
+func verifyError(sdPath string, offset uint32, got uint32, want uint32, attempts int) error {
+	return fmt.Errorf("[Verify] sd:%s %w at offset 0x%X, CRC 0x%08X expected 0x%08X after %d attempts",
+		sdPath, ErrVerify, offset, got, want, attempts)
+}
+
+// writeMemoryVerified writes data to memory on the N8.
+//
+// If `Verify` is set the N8 calculates the CRC of the memory and the
+// write is retried until it matches the data.
+func (n8 *N8) writeMemoryVerified(addr uint32, data []uint8, length uint32) error {
+	want := crc32.ChecksumIEEE(data[:length])
+
+	for attempt := 1; ; attempt++ {
+		if err := n8.WriteMemory(addr, data, length); err != nil {
+			return err
+		}
+		if !n8.Verify {
+			return nil
+		}
+
+		got, err := n8.MemoryCrc(addr, length)
+		if err != nil {
+			return err
+		}
+		if got == want {
+			return nil
+		}
+		if attempt == VERIFY_ATTEMPTS {
+			return fmt.Errorf("[Verify] memory 0x%08X %w, CRC 0x%08X expected 0x%08X after %d attempts", addr, ErrVerify, got, want, attempt)
+		}
+	}
+}
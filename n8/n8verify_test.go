@@ -0,0 +1,108 @@
+package n8_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"forge.rights.ninja/jeff/goedlink/n8"
+)
+
+// corruptTransport flips a byte in the next `writes` blocks written and
+// the next `reads` large reads.
+type corruptTransport struct {
+	n8.Transport
+	writes int
+	reads  int
+}
+
+func (t *corruptTransport) Write(buf []uint8) (int, error) {
+	if len(buf) < (int)(n8.ACK_BLOCK_SIZE) || t.writes == 0 {
+		return t.Transport.Write(buf)
+	}
+
+	t.writes--
+	corrupt := append([]uint8(nil), buf...)
+	corrupt[len(corrupt)/2] ^= 0xFF
+	return t.Transport.Write(corrupt)
+}
+
+func (t *corruptTransport) Read(buf []uint8) (int, error) {
+	n, err := t.Transport.Read(buf)
+	if n > 0x800 && t.reads > 0 {
+		t.reads--
+		buf[n-1] ^= 0xFF // file data, the status bytes lead each block
+	}
+
+	return n, err
+}
+
+func newCorruptN8(t *testing.T) (*n8.N8, *corruptTransport) {
+	dev, _ := newEmulatedN8(t)
+	transport := &corruptTransport{Transport: dev.Port}
+	dev.Port = transport
+
+	return dev, transport
+}
+
+func TestVerifyWrite(t *testing.T) {
+	data := testData(0x2000)
+
+	for _, test := range []struct {
+		name    string
+		verify  bool
+		writes  int
+		wantErr error
+		intact  bool
+	}{
+		{"clean", true, 0, nil, true},
+		{"retried", true, 2, nil, true},
+		{"gives up", true, 100, n8.ErrVerify, false},
+		{"unverified", false, 1, nil, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dev, transport := newCorruptN8(t)
+			dev.Verify = test.verify
+			transport.writes = test.writes
+
+			_, err := dev.WriteFileFrom("verify.bin", bytes.NewReader(data))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("WriteFileFrom = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var got bytes.Buffer
+			if _, err := dev.ReadFileTo("verify.bin", &got); err != nil {
+				t.Fatal(err)
+			}
+			if intact := bytes.Equal(got.Bytes(), data); intact != test.intact {
+				t.Errorf("file intact = %v, want %v", intact, test.intact)
+			}
+		})
+	}
+}
+
+func TestVerifyRead(t *testing.T) {
+	dev, transport := newCorruptN8(t)
+	data := testData(0x2000)
+	if _, err := dev.WriteFileFrom("verify.bin", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	dev.Verify = true
+	transport.reads = 2
+	var got bytes.Buffer
+	if _, err := dev.ReadFileTo("verify.bin", &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), data) {
+		t.Error("verified read does not match")
+	}
+
+	transport.reads = 100
+	if _, err := dev.ReadFileTo("verify.bin", &bytes.Buffer{}); !errors.Is(err, n8.ErrVerify) {
+		t.Errorf("ReadFileTo = %v, want ErrVerify", err)
+	}
+}
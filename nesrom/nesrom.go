@@ -4,8 +4,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"hash/crc32"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 const (
@@ -19,6 +22,9 @@ const (
 	ADDR_OS_CHR uint32 = (ADDR_CHR + 0x7E0000)
 )
 
+// ADDR_TRAINER is where a trainer is loaded, CPU $7000 in PRG RAM.
+const ADDR_TRAINER uint32 = (ADDR_SRM + 0x1000)
+
 const (
 	ROM_TYPE_NES uint32 = 0
 	ROM_TYPE_FDS uint32 = 1
@@ -31,17 +37,44 @@ const (
 
 	FDS_DISK_SIZE uint32 = 65500
 
+	INES_HEADER_SIZE uint32 = 16
+	TRAINER_SIZE     uint32 = 512
+
+	// PRG and CHR each have 8 MiB of memory on the N8
+	MAX_PRG_SIZE uint32 = ADDR_CHR - ADDR_PRG
+	MAX_CHR_SIZE uint32 = ADDR_SRM - ADDR_CHR
+
 	MAX_ID_CALC_LEN uint32 = 0x100000
 )
 
+// NES 2.0 CPU/PPU timing, byte 12.
+const (
+	TIMING_NTSC  uint8 = 0
+	TIMING_PAL   uint8 = 1
+	TIMING_MULTI uint8 = 2
+	TIMING_DENDY uint8 = 3
+)
+
+// Console type, byte 7. CONSOLE_EXTENDED types come from byte 13 and
+// are returned as CONSOLE_EXTENDED + that type.
+const (
+	CONSOLE_NES      uint8 = 0
+	CONSOLE_VS       uint8 = 1
+	CONSOLE_PC10     uint8 = 2
+	CONSOLE_EXTENDED uint8 = 3
+)
+
 type NesRom struct {
 	romPath   string
+	data      []uint8 // the image loaded on the N8
 	prg       []uint8
 	chr       []uint8
+	trainer   []uint8
 	ines      []uint8
 	crc       uint32
 	srmSize   uint32
-	mapper    uint8
+	mapper    uint16
+	submapper uint8
 	mirroring uint8
 	batRam    bool
 	datBase   uint32
@@ -49,6 +82,19 @@ type NesRom struct {
 	romType   uint32
 	prgAddr   uint32
 	chrAddr   uint32
+
+	// NES 2.0 only
+	nes20        bool
+	prgRamSize   uint32
+	prgNvramSize uint32
+	chrRamSize   uint32
+	chrNvramSize uint32
+	timing       uint8
+	console      uint8
+
+	board string // UNIF only
+
+	warnings []string
 }
 
 // type NesRom struct {
@@ -74,37 +120,65 @@ func NewNesRom(path string) (*NesRom, error) {
 		size:    (uint32)(len(rom)),
 		ines:    make([]uint8, 32),
 	}
-	copy(n.ines, rom[:32])
+	copy(n.ines, rom)
 
+	unif := string(n.ines[:4]) == "UNIF"
 	nes := n.ines[0] == 'N' && n.ines[1] == 'E' && n.ines[2] == 'S'
 	fds00 := n.ines[11] == 'H' && n.ines[12] == 'V' && n.ines[13] == 'C'
 	fds16 := n.ines[11+16] == 'H' && n.ines[12+16] == 'V' && n.ines[13+16] == 'C'
 
 	switch {
-	case nes:
-		n.romType = ROM_TYPE_NES
+	case unif:
+		if err := n.parseUnif(rom); err != nil {
+			return nil, err
+		}
+		// the N8 only loads iNES, so the ROM ID is for the converted image
+		rom = n.ToINes()
+		n.size = (uint32)(len(rom))
 		n.datBase = 16
-		n.prgAddr = ADDR_PRG
-		prgSize := uint32(rom[4]) * 1024 * 16
-		chrSize := uint32(rom[5]) * 1024 * 8
+		clear(n.ines)
+		copy(n.ines, rom)
+	case nes:
+		if (uint32)(len(rom)) < INES_HEADER_SIZE {
+			return nil, fmt.Errorf("iNES header is truncated, file is %d bytes", len(rom))
+		}
+		h := n.cleanHeader(rom)
+		n.datBase = INES_HEADER_SIZE
+		prgSize := uint32(h[4]) * 1024 * 16
+		chrSize := uint32(h[5]) * 1024 * 8
 		n.srmSize = 8192
 		if prgSize == 0 {
 			prgSize = 0x400000
 		}
-		n.mapper = (rom[6] >> 4) | (rom[7] & 0xf0)
-		if rom[6]&1 == 0 {
+		n.setMapper((uint16)((h[6] >> 4) | (h[7] & 0xf0)))
+		n.console = h[7] & 0x03
+		if h[7]&0x0C == 0x08 {
+			n.parseNes20()
+			if prgSize, err = nes20Size(h[4], h[9]&0x0f, 0x4000); err != nil {
+				return nil, err
+			}
+			if chrSize, err = nes20Size(h[5], h[9]>>4, 0x2000); err != nil {
+				return nil, err
+			}
+		}
+		if h[6]&1 == 0 {
 			n.mirroring = MIR_HOR
 		} else {
 			n.mirroring = MIR_VER
 		}
-		n.batRam = rom[6]&2 != 0
-		if rom[6]&8 != 0 {
+		n.batRam = h[6]&2 != 0
+		if h[6]&8 != 0 {
 			n.mirroring = MIR_4SC
 		}
-		if n.mapper == 255 {
-			n.romType = ROM_TYPE_OS
-			n.prgAddr = ADDR_OS_PRG
-			n.chrAddr = ADDR_OS_CHR
+		if h[6]&4 != 0 {
+			n.datBase += TRAINER_SIZE
+		}
+		if err := n.checkSizes((uint32)(len(rom)), prgSize, chrSize); err != nil {
+			return nil, err
+		}
+		if h[6]&4 != 0 {
+			n.trainer = make([]uint8, TRAINER_SIZE)
+			copy(n.trainer, rom[INES_HEADER_SIZE:])
 		}
 		n.prg = make([]uint8, prgSize)
 		n.chr = make([]uint8, chrSize)
@@ -118,6 +192,12 @@ func NewNesRom(path string) (*NesRom, error) {
 			n.datBase = 16
 		}
 		n.prgAddr = ADDR_SRM
+		if (uint32)(len(rom))-n.datBase < FDS_DISK_SIZE {
+			return nil, fmt.Errorf("FDS image is truncated, file is %d bytes", len(rom))
+		}
+		if ((uint32)(len(rom))-n.datBase)%FDS_DISK_SIZE != 0 {
+			n.warn("FDS image is not a whole number of %d byte disk sides", FDS_DISK_SIZE)
+		}
 		prgSize := ((uint32)(len(rom)) - n.datBase) / FDS_DISK_SIZE * 0x10000
 		if prgSize < (uint32)(len(rom)) {
 			prgSize += 0x10000
@@ -145,18 +225,201 @@ func NewNesRom(path string) (*NesRom, error) {
 		crcLen = MAX_ID_CALC_LEN
 	}
 	n.crc = crc32.ChecksumIEEE(rom[n.datBase : n.datBase+crcLen])
+	n.data = rom
 
 	return n, nil
 }
 
+// setMapper sets the mapper along with the ROM type and load addresses,
+// as mapper 255 is the OS and is loaded elsewhere.
+func (n *NesRom) setMapper(mapper uint16) {
+	n.mapper = mapper
+	n.romType = ROM_TYPE_NES
+	n.prgAddr = ADDR_PRG
+	n.chrAddr = 0
+	if mapper == 255 {
+		n.romType = ROM_TYPE_OS
+		n.prgAddr = ADDR_OS_PRG
+		n.chrAddr = ADDR_OS_CHR
+	}
+}
+
+// cleanHeader returns a copy of the iNES header in rom to parse.
+//
+// Old tools wrote their name into the unused bytes 7-15, eg. "DiskDude!",
+// which makes the high nibble of the mapper number garbage. Unless the
+// header is NES 2.0, bytes 7-15 are cleared in rom if bytes 12-15 are not
+// zero, so the N8 is given the header as parsed.
+func (n *NesRom) cleanHeader(rom []uint8) []uint8 {
+	h := make([]uint8, INES_HEADER_SIZE)
+	copy(h, rom)
+	if h[7]&0x0C == 0x08 {
+		return h
+	}
+
+	if string(h[7:16]) == "DiskDude!" {
+		n.warn("header contains \"DiskDude!\", clearing bytes 7-15")
+	} else if binary.LittleEndian.Uint32(h[12:16]) != 0 {
+		n.warn("header bytes 12-15 are not zero, clearing bytes 7-15")
+	} else {
+		return h
+	}
+	clear(h[7:16])
+	copy(rom, h)
+	copy(n.ines, h)
+
+	return h
+}
+
+// checkSizes checks the file holds the PRG and CHR given by the header
+// and that they fit on the N8.
+func (n *NesRom) checkSizes(fileSize uint32, prgSize uint32, chrSize uint32) error {
+	if err := checkLimits(prgSize, chrSize); err != nil {
+		return err
+	}
+
+	expected := n.datBase + prgSize + chrSize
+	if fileSize < expected {
+		return fmt.Errorf("ROM is truncated, header gives %d bytes, file is %d bytes", expected, fileSize)
+	}
+
+	// PlayChoice-10 and NES 2.0 miscellaneous ROMs follow the CHR data
+	extra := fileSize - expected
+	miscRoms := n.console == CONSOLE_PC10 || n.nes20 && n.ines[14]&0x03 != 0
+	if extra > 0 && !miscRoms {
+		n.warn("%d bytes after the CHR data are ignored", extra)
+	}
+
+	return nil
+}
+
+// checkLimits checks the PRG and CHR ROM fit on the N8.
+func checkLimits(prgSize uint32, chrSize uint32) error {
+	if prgSize > MAX_PRG_SIZE {
+		return fmt.Errorf("PRG ROM is %dK, the N8 has room for %dK", prgSize/1024, MAX_PRG_SIZE/1024)
+	}
+	if chrSize > MAX_CHR_SIZE {
+		return fmt.Errorf("CHR ROM is %dK, the N8 has room for %dK", chrSize/1024, MAX_CHR_SIZE/1024)
+	}
+
+	return nil
+}
+
+// warn adds a warning, once however often it is given.
+func (n *NesRom) warn(format string, args ...any) {
+	warning := fmt.Sprintf(format, args...)
+	if !slices.Contains(n.warnings, warning) {
+		n.warnings = append(n.warnings, warning)
+	}
+}
+
+// parseNes20 reads the NES 2.0 fields from the header, apart from the
+// PRG and CHR sizes.
+func (n *NesRom) parseNes20() {
+	h := n.ines
+
+	n.nes20 = true
+	n.setMapper(n.mapper | (uint16)(h[8]&0x0f)<<8)
+	n.submapper = h[8] >> 4
+	n.prgRamSize = shiftSize(h[10] & 0x0f)
+	n.prgNvramSize = shiftSize(h[10] >> 4)
+	n.chrRamSize = shiftSize(h[11] & 0x0f)
+	n.chrNvramSize = shiftSize(h[11] >> 4)
+	n.timing = h[12] & 0x03
+	if n.console == CONSOLE_EXTENDED {
+		n.console = CONSOLE_EXTENDED + h[13]&0x0f
+	}
+	n.srmSize = n.prgRamSize + n.prgNvramSize
+}
+
+// nes20Size decodes a NES 2.0 PRG or CHR size from its LSB byte and MSB
+// nibble. An MSB of 0xF means the LSB holds an exponent and multiplier,
+// otherwise the size is a count of units.
+func nes20Size(lsb uint8, msb uint8, unit uint32) (uint32, error) {
+	if msb != 0x0f {
+		return ((uint32)(msb)<<8 | (uint32)(lsb)) * unit, nil
+	}
+
+	exponent := lsb >> 2
+	multiplier := (uint64)(lsb&0x03)*2 + 1
+	size := ((uint64)(1) << exponent) * multiplier
+	if size > math.MaxUint32 {
+		return 0, fmt.Errorf("NES 2.0 ROM size 2^%d x %d is too large", exponent, multiplier)
+	}
+
+	return (uint32)(size), nil
+}
+
+// shiftSize decodes a NES 2.0 RAM shift count, 0 means no RAM.
+func shiftSize(shift uint8) uint32 {
+	if shift == 0 {
+		return 0
+	}
+
+	return 64 << shift
+}
+
 func (n *NesRom) Print() {
-	fmt.Printf("Mapper   : %d\n", n.mapper)
+	if n.board != "" {
+		fmt.Printf("Format   : UNIF, board %s\n", n.board)
+		fmt.Printf("Mapper   : %d.%d\n", n.mapper, n.submapper)
+	} else if n.nes20 {
+		fmt.Printf("Format   : NES 2.0\n")
+		fmt.Printf("Mapper   : %d.%d\n", n.mapper, n.submapper)
+	} else {
+		fmt.Printf("Mapper   : %d\n", n.mapper)
+	}
 	fmt.Printf("PRG SIZE : %dK (%d x 16K)\n", len(n.prg)/1024, len(n.prg)/1024/16)
 	fmt.Printf("CHR SIZE : %dK (%d x 8K)\n", len(n.chr)/1024, len(n.chr)/1024/8)
 	fmt.Printf("SRM SIZE : %dK\n", n.srmSize/1024)
+	if n.nes20 {
+		fmt.Printf("PRG RAM  : %s, NVRAM %s\n", sizeString(n.prgRamSize), sizeString(n.prgNvramSize))
+		fmt.Printf("CHR RAM  : %s, NVRAM %s\n", sizeString(n.chrRamSize), sizeString(n.chrNvramSize))
+		fmt.Printf("Timing   : %s\n", TimingName(n.timing))
+		fmt.Printf("Console  : %s\n", ConsoleName(n.console))
+	}
 	fmt.Printf("Mirroring: %c\n", n.mirroring)
 	fmt.Printf("BAT RAM  : %s\n", boolToString(n.batRam))
+	fmt.Printf("Trainer  : %s\n", boolToString(n.trainer != nil))
 	fmt.Printf("ROM ID   : 0x%08X\n", n.crc)
+	for _, warning := range n.warnings {
+		fmt.Printf("Warning  : %s\n", warning)
+	}
+}
+
+func sizeString(size uint32) string {
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%dK", size/1024)
+}
+
+// TimingName returns the name of a TIMING_* value.
+func TimingName(timing uint8) string {
+	switch timing {
+	case TIMING_NTSC:
+		return "NTSC"
+	case TIMING_PAL:
+		return "PAL"
+	case TIMING_MULTI:
+		return "multi-region"
+	case TIMING_DENDY:
+		return "Dendy"
+	}
+	return "?"
+}
+
+// ConsoleName returns the name of a CONSOLE_* value.
+func ConsoleName(console uint8) string {
+	switch console {
+	case CONSOLE_NES:
+		return "NES/Famicom"
+	case CONSOLE_VS:
+		return "Vs. System"
+	case CONSOLE_PC10:
+		return "PlayChoice-10"
+	}
+	return fmt.Sprintf("extended type %d", console-CONSOLE_EXTENDED)
 }
 
 func boolToString(b bool) string {
@@ -186,10 +449,70 @@ func (n *NesRom) GetChrAddr() uint32 {
 	return n.chrAddr
 }
 
-func (n *NesRom) GetMapper() uint8 {
+func (n *NesRom) GetMapper() uint16 {
 	return n.mapper
 }
 
+// GetSubmapper returns the NES 2.0 submapper, 0 for iNES ROMs.
+func (n *NesRom) GetSubmapper() uint8 {
+	return n.submapper
+}
+
+// GetFormat returns the format of the file: "iNES", "NES 2.0", "UNIF"
+// or "FDS".
+func (n *NesRom) GetFormat() string {
+	switch {
+	case n.board != "":
+		return "UNIF"
+	case n.romType == ROM_TYPE_FDS:
+		return "FDS"
+	case n.nes20:
+		return "NES 2.0"
+	}
+	return "iNES"
+}
+
+// IsNes20 reports whether the ROM has a NES 2.0 header.
+func (n *NesRom) IsNes20() bool {
+	return n.nes20
+}
+
+// GetPrgRamSize returns the size of volatile PRG RAM, NES 2.0 only.
+func (n *NesRom) GetPrgRamSize() uint32 {
+	return n.prgRamSize
+}
+
+// GetPrgNvramSize returns the size of battery backed PRG RAM, NES 2.0
+// only.
+func (n *NesRom) GetPrgNvramSize() uint32 {
+	return n.prgNvramSize
+}
+
+// GetChrRamSize returns the size of volatile CHR RAM, NES 2.0 only.
+func (n *NesRom) GetChrRamSize() uint32 {
+	return n.chrRamSize
+}
+
+// GetChrNvramSize returns the size of battery backed CHR RAM, NES 2.0
+// only.
+func (n *NesRom) GetChrNvramSize() uint32 {
+	return n.chrNvramSize
+}
+
+// GetTiming returns one of the TIMING_* values, NES 2.0 only.
+func (n *NesRom) GetTiming() uint8 {
+	return n.timing
+}
+
+// GetConsoleType returns one of the CONSOLE_* values.
+func (n *NesRom) GetConsoleType() uint8 {
+	return n.console
+}
+
+func (n *NesRom) IsBatteryRam() bool {
+	return n.batRam
+}
+
 func (n *NesRom) GetMirroring() uint8 {
 	return n.mirroring
 }
@@ -198,8 +521,42 @@ func (n *NesRom) GetType() uint32 {
 	return n.romType
 }
 
+// GetName returns the file name of the ROM, UNIF ROMs get a .nes
+// extension as they are loaded as iNES.
 func (n *NesRom) GetName() string {
-	return filepath.Base(n.romPath)
+	name := filepath.Base(n.romPath)
+	if n.board != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ".nes"
+	}
+
+	return name
+}
+
+// GetRomData returns the image to load on the N8, the file as read for
+// iNES and FDS ROMs or the converted image for UNIF ROMs.
+func (n *NesRom) GetRomData() []uint8 {
+	return n.data
+}
+
+// IsUnif reports whether the ROM was read from a UNIF file.
+func (n *NesRom) IsUnif() bool {
+	return n.board != ""
+}
+
+// GetBoard returns the UNIF board name.
+func (n *NesRom) GetBoard() string {
+	return n.board
+}
+
+// GetTrainer returns the 512 byte trainer, nil if the ROM has none.
+func (n *NesRom) GetTrainer() []uint8 {
+	return n.trainer
+}
+
+// GetWarnings returns problems found in the ROM that did not stop it
+// loading, such as garbage in the header or data after the CHR ROM.
+func (n *NesRom) GetWarnings() []string {
+	return n.warnings
 }
 
 func (n *NesRom) GetPrgData() []uint8 {
@@ -210,6 +567,12 @@ func (n *NesRom) GetChrData() []uint8 {
 	return n.chr
 }
 
+// GetRomCrc returns the CRC32 printed as the ROM ID, of the data after
+// the header and trainer, up to MAX_ID_CALC_LEN bytes.
+func (n *NesRom) GetRomCrc() uint32 {
+	return n.crc
+}
+
 func (n *NesRom) GetRomID() []uint8 {
 	bin := make([]uint8, len(n.ines)+4*3)
 	ptr := 0
@@ -0,0 +1,192 @@
+package nesrom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// romData returns length bytes of a repeating, non-zero pattern.
+func romData(length uint32) []uint8 {
+	data := make([]uint8, length)
+	for i := range data {
+		data[i] = (uint8)(i*13 + i>>8 + 1)
+	}
+
+	return data
+}
+
+// loadRom writes data to a temporary file named name and parses it.
+func loadRom(t *testing.T, name string, data []uint8) (*NesRom, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewNesRom(path)
+}
+
+// inesRom returns an image with the given header, followed by prgSize
+// bytes of PRG ROM and chrSize bytes of CHR ROM.
+func inesRom(header []uint8, prgSize uint32, chrSize uint32) []uint8 {
+	data := make([]uint8, INES_HEADER_SIZE)
+	copy(data, header)
+
+	return append(data, romData(prgSize+chrSize)...)
+}
+
+func TestINes(t *testing.T) {
+	data := inesRom([]uint8{'N', 'E', 'S', 0x1A, 2, 1, 0x43, 0x00}, 0x8000, 0x2000)
+	rom, err := loadRom(t, "game.nes", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case rom.GetFormat() != "iNES":
+		t.Errorf("format = %s, want iNES", rom.GetFormat())
+	case rom.GetMapper() != 4:
+		t.Errorf("mapper = %d, want 4", rom.GetMapper())
+	case rom.GetPrgSize() != 0x8000 || rom.GetChrSize() != 0x2000:
+		t.Errorf("PRG %d, CHR %d, want 32768 and 8192", rom.GetPrgSize(), rom.GetChrSize())
+	case rom.GetMirroring() != MIR_VER || !rom.IsBatteryRam():
+		t.Errorf("mirroring %c, battery %v, want V and true", rom.GetMirroring(), rom.IsBatteryRam())
+	case !bytes.Equal(rom.GetPrgData(), data[16:16+0x8000]):
+		t.Error("PRG data does not match")
+	case !bytes.Equal(rom.GetChrData(), data[16+0x8000:]):
+		t.Error("CHR data does not match")
+	case !bytes.Equal(rom.GetRomData(), data):
+		t.Error("ROM data is not the file")
+	case len(rom.GetWarnings()) != 0:
+		t.Errorf("unexpected warnings %q", rom.GetWarnings())
+	}
+}
+
+func TestNes20(t *testing.T) {
+	header := []uint8{
+		'N', 'E', 'S', 0x1A,
+		2, 1, // PRG and CHR size LSB
+		0x33,       // mapper 3, battery, vertical
+		0x28,       // mapper 2, NES 2.0
+		0x51,       // submapper 5, mapper 1
+		0x00,       // size MSBs
+		0x70,       // 8 KiB PRG NVRAM
+		0x07,       // 8 KiB CHR RAM
+		TIMING_PAL, // timing
+	}
+	rom, err := loadRom(t, "game.nes", inesRom(header, 0x8000, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rom.IsNes20() || rom.GetFormat() != "NES 2.0" {
+		t.Errorf("format = %s, want NES 2.0", rom.GetFormat())
+	}
+	if rom.GetMapper() != 0x123 || rom.GetSubmapper() != 5 {
+		t.Errorf("mapper %d.%d, want 291.5", rom.GetMapper(), rom.GetSubmapper())
+	}
+	if rom.GetPrgRamSize() != 0 || rom.GetPrgNvramSize() != 0x2000 || rom.GetChrRamSize() != 0x2000 || rom.GetChrNvramSize() != 0 {
+		t.Errorf("RAM %d/%d/%d/%d, want 0/8192/8192/0",
+			rom.GetPrgRamSize(), rom.GetPrgNvramSize(), rom.GetChrRamSize(), rom.GetChrNvramSize())
+	}
+	if rom.GetSrmSize() != 0x2000 {
+		t.Errorf("SRM size %d, want 8192", rom.GetSrmSize())
+	}
+	if rom.GetTiming() != TIMING_PAL {
+		t.Errorf("timing %s, want PAL", TimingName(rom.GetTiming()))
+	}
+}
+
+func TestNes20Size(t *testing.T) {
+	for _, test := range []struct {
+		lsb, msb uint8
+		unit     uint32
+		want     uint32
+	}{
+		{0x02, 0x0, 0x4000, 0x8000},
+		{0x00, 0x1, 0x4000, 0x400000},
+		{0x4C, 0xF, 0x4000, 1 << 19},      // 2^19 x 1
+		{0x09, 0xF, 0x2000, (1 << 2) * 3}, // 2^2 x 3
+		{0x0B, 0xF, 0x2000, (1 << 2) * 7}, // 2^2 x 7
+	} {
+		got, err := nes20Size(test.lsb, test.msb, test.unit)
+		if err != nil || got != test.want {
+			t.Errorf("nes20Size(%02X, %X) = %d, %v, want %d", test.lsb, test.msb, got, err, test.want)
+		}
+
+		// encoding the size again gives the same size
+		lsb, msb, size := nes20SizeFields(test.want, test.unit)
+		if again, _ := nes20Size(lsb, msb, test.unit); size != test.want || again != test.want {
+			t.Errorf("nes20SizeFields(%d) = %02X, %X, %d", test.want, lsb, msb, size)
+		}
+	}
+
+	if _, err := nes20Size(0xFF, 0xF, 0x4000); err == nil {
+		t.Error("nes20Size of 2^63 x 7 did not fail")
+	}
+}
+
+func TestTrainer(t *testing.T) {
+	trainer := bytes.Repeat([]uint8{0xA5}, (int)(TRAINER_SIZE))
+	data := inesRom([]uint8{'N', 'E', 'S', 0x1A, 1, 1, 0x04}, 0, 0)
+	data = append(data, trainer...)
+	data = append(data, romData(0x4000+0x2000)...)
+	rom, err := loadRom(t, "game.nes", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case !bytes.Equal(rom.GetTrainer(), trainer):
+		t.Error("trainer does not match")
+	case rom.datBase != INES_HEADER_SIZE+TRAINER_SIZE:
+		t.Errorf("PRG at 0x%X, want 0x%X", rom.datBase, INES_HEADER_SIZE+TRAINER_SIZE)
+	case !bytes.Equal(rom.GetPrgData(), data[16+TRAINER_SIZE:16+TRAINER_SIZE+0x4000]):
+		t.Error("PRG data does not follow the trainer")
+	}
+}
+
+func TestDiskDude(t *testing.T) {
+	header := []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0x10, 'D', 'i', 's', 'k', 'D', 'u', 'd', 'e', '!'}
+	rom, err := loadRom(t, "game.nes", inesRom(header, 0x4000, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rom.GetMapper() != 1 {
+		t.Errorf("mapper = %d, want 1", rom.GetMapper())
+	}
+	if len(rom.GetWarnings()) != 1 || !strings.Contains(rom.GetWarnings()[0], "DiskDude") {
+		t.Errorf("warnings %q, want one about DiskDude", rom.GetWarnings())
+	}
+
+	// the N8 is given the cleaned header
+	want := []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(rom.GetRomData()[:INES_HEADER_SIZE], want) {
+		t.Errorf("ROM header % X, want % X", rom.GetRomData()[:INES_HEADER_SIZE], want)
+	}
+}
+
+func TestINesSizes(t *testing.T) {
+	header := []uint8{'N', 'E', 'S', 0x1A, 2, 1}
+
+	data := inesRom(header, 0x8000, 0x2000)
+	if _, err := loadRom(t, "game.nes", data[:len(data)-1]); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("truncated ROM error %v, want truncated", err)
+	}
+	if _, err := loadRom(t, "game.nes", data[:10]); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("truncated header error %v, want truncated", err)
+	}
+
+	rom, err := loadRom(t, "game.nes", append(data, 0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rom.GetWarnings()) != 1 || !strings.Contains(rom.GetWarnings()[0], "3 bytes after the CHR data") {
+		t.Errorf("warnings %q, want one about 3 extra bytes", rom.GetWarnings())
+	}
+}
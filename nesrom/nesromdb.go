@@ -0,0 +1,270 @@
+package nesrom
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Header holds the correct header values for a ROM, as found in a
+// header database.
+type Header struct {
+	Name         string
+	Mapper       uint16
+	Submapper    uint8
+	Mirroring    uint8 // one of MIR_*, 0 leaves the mirroring as it is
+	Battery      bool
+	PrgRamSize   uint32
+	PrgNvramSize uint32
+	ChrRamSize   uint32
+	ChrNvramSize uint32
+}
+
+// HeaderDB finds the correct header of a ROM by the CRC32 of its PRG
+// and CHR ROM, see `NesRom.GetPrgChrCrc`.
+type HeaderDB interface {
+	Lookup(crc uint32) (*Header, bool)
+}
+
+// HeaderMap is a HeaderDB held in memory.
+type HeaderMap map[uint32]*Header
+
+func (m HeaderMap) Lookup(crc uint32) (*Header, bool) {
+	header, ok := m[crc]
+	return header, ok
+}
+
+// LoadHeaderDB reads a header database from a file.
+//
+// The file is either NES 2.0 DB XML, where each game is looked up by
+// the CRC32 of its `rom` element, or a JSON array of objects with
+// "crc32", "name", "mapper", "submapper", "mirroring" ("H", "V", "4" or
+// "1"), "battery", "prgRamSize", "prgNvramSize", "chrRamSize" and
+// "chrNvramSize".
+func LoadHeaderDB(path string) (HeaderMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var db HeaderMap
+	if bytes.HasPrefix(bytes.TrimSpace(data), []uint8("<")) {
+		db, err = parseNes20DB(data)
+	} else {
+		db, err = parseJSONHeaderDB(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("header database %s: %w", path, err)
+	}
+
+	return db, nil
+}
+
+type nes20DBSize struct {
+	Size uint32 `xml:"size,attr"`
+}
+
+type nes20DB struct {
+	Games []struct {
+		Comment string `xml:",comment"`
+		Rom     struct {
+			Crc32 string `xml:"crc32,attr"`
+		} `xml:"rom"`
+		Pcb struct {
+			Mapper    uint16 `xml:"mapper,attr"`
+			Submapper uint8  `xml:"submapper,attr"`
+			Mirroring string `xml:"mirroring,attr"`
+			Battery   uint8  `xml:"battery,attr"`
+		} `xml:"pcb"`
+		PrgRam   nes20DBSize `xml:"prgram"`
+		PrgNvram nes20DBSize `xml:"prgnvram"`
+		ChrRam   nes20DBSize `xml:"chrram"`
+		ChrNvram nes20DBSize `xml:"chrnvram"`
+	} `xml:"game"`
+}
+
+func parseNes20DB(data []uint8) (HeaderMap, error) {
+	var doc nes20DB
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	db := make(HeaderMap, len(doc.Games))
+	for _, game := range doc.Games {
+		crc, err := parseCrc(game.Rom.Crc32)
+		if err != nil {
+			return nil, err
+		}
+		mirroring, err := parseMirroring(game.Pcb.Mirroring)
+		if err != nil {
+			return nil, err
+		}
+
+		db[crc] = &Header{
+			Name:         strings.TrimSpace(game.Comment),
+			Mapper:       game.Pcb.Mapper,
+			Submapper:    game.Pcb.Submapper,
+			Mirroring:    mirroring,
+			Battery:      game.Pcb.Battery != 0,
+			PrgRamSize:   game.PrgRam.Size,
+			PrgNvramSize: game.PrgNvram.Size,
+			ChrRamSize:   game.ChrRam.Size,
+			ChrNvramSize: game.ChrNvram.Size,
+		}
+	}
+
+	return db, nil
+}
+
+type jsonHeader struct {
+	Crc32        string `json:"crc32"`
+	Name         string `json:"name"`
+	Mapper       uint16 `json:"mapper"`
+	Submapper    uint8  `json:"submapper"`
+	Mirroring    string `json:"mirroring"`
+	Battery      bool   `json:"battery"`
+	PrgRamSize   uint32 `json:"prgRamSize"`
+	PrgNvramSize uint32 `json:"prgNvramSize"`
+	ChrRamSize   uint32 `json:"chrRamSize"`
+	ChrNvramSize uint32 `json:"chrNvramSize"`
+}
+
+func parseJSONHeaderDB(data []uint8) (HeaderMap, error) {
+	var entries []jsonHeader
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	db := make(HeaderMap, len(entries))
+	for _, entry := range entries {
+		crc, err := parseCrc(entry.Crc32)
+		if err != nil {
+			return nil, err
+		}
+		mirroring, err := parseMirroring(entry.Mirroring)
+		if err != nil {
+			return nil, err
+		}
+
+		db[crc] = &Header{
+			Name:         entry.Name,
+			Mapper:       entry.Mapper,
+			Submapper:    entry.Submapper,
+			Mirroring:    mirroring,
+			Battery:      entry.Battery,
+			PrgRamSize:   entry.PrgRamSize,
+			PrgNvramSize: entry.PrgNvramSize,
+			ChrRamSize:   entry.ChrRamSize,
+			ChrNvramSize: entry.ChrNvramSize,
+		}
+	}
+
+	return db, nil
+}
+
+func parseCrc(s string) (uint32, error) {
+	crc, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad crc32 %q", s)
+	}
+
+	return (uint32)(crc), nil
+}
+
+func parseMirroring(s string) (uint8, error) {
+	switch strings.ToUpper(s) {
+	case "":
+		return 0, nil
+	case "H":
+		return MIR_HOR, nil
+	case "V":
+		return MIR_VER, nil
+	case "4":
+		return MIR_4SC, nil
+	case "1":
+		return MIR_1SC, nil
+	}
+
+	return 0, fmt.Errorf("bad mirroring %q", s)
+}
+
+// GetPrgChrCrc returns the CRC32 of the PRG ROM followed by the CHR ROM,
+// which is how a HeaderDB finds the ROM.
+func (n *NesRom) GetPrgChrCrc() uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(n.prg), crc32.IEEETable, n.chr)
+}
+
+// CheckHeader looks the ROM up in db, returning a description of each
+// header value that differs and whether the ROM was found. The ROM is
+// left as it is, FDS images have no header and are never found.
+func (n *NesRom) CheckHeader(db HeaderDB) ([]string, bool) {
+	if n.romType == ROM_TYPE_FDS {
+		return nil, false
+	}
+	header, ok := db.Lookup(n.GetPrgChrCrc())
+	if !ok {
+		return nil, false
+	}
+
+	prgRam, prgNvram, chrRam, chrNvram := n.ramSizes()
+
+	var changes []string
+	change := func(name string, from any, to any) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s %v -> %v", name, from, to))
+		}
+	}
+	change("mapper", n.mapper, header.Mapper)
+	change("submapper", n.submapper, header.Submapper)
+	if header.Mirroring != 0 {
+		change("mirroring", string(rune(n.mirroring)), string(rune(header.Mirroring)))
+	}
+	change("battery", boolToString(n.batRam), boolToString(header.Battery))
+	change("PRG RAM", sizeString(prgRam), sizeString(header.PrgRamSize))
+	change("PRG NVRAM", sizeString(prgNvram), sizeString(header.PrgNvramSize))
+	change("CHR RAM", sizeString(chrRam), sizeString(header.ChrRamSize))
+	change("CHR NVRAM", sizeString(chrNvram), sizeString(header.ChrNvramSize))
+
+	return changes, true
+}
+
+// FixHeader corrects the header values that CheckHeader finds differ
+// from db, returning the same.
+//
+// A corrected ROM is given a NES 2.0 header, which is part of the data
+// returned by GetRomData. Changing the mapper to or from 255 also moves
+// the ROM between a game and the OS.
+func (n *NesRom) FixHeader(db HeaderDB) ([]string, bool) {
+	changes, found := n.CheckHeader(db)
+	if len(changes) == 0 {
+		return nil, found
+	}
+	header, _ := db.Lookup(n.GetPrgChrCrc())
+
+	n.setMapper(header.Mapper)
+	n.submapper = header.Submapper
+	if header.Mirroring != 0 {
+		n.mirroring = header.Mirroring
+	}
+	n.batRam = header.Battery
+	n.prgRamSize = header.PrgRamSize
+	n.prgNvramSize = header.PrgNvramSize
+	n.chrRamSize = header.ChrRamSize
+	n.chrNvramSize = header.ChrNvramSize
+	n.srmSize = n.prgRamSize + n.prgNvramSize
+	if !n.nes20 {
+		clear(n.ines[8:16]) // unused or garbage in iNES headers
+		n.nes20 = true
+	}
+
+	h, _, _ := n.nes20Header()
+	n.data = append(h, n.data[INES_HEADER_SIZE:]...)
+	copy(n.ines, h)
+
+	return changes, true
+}
@@ -0,0 +1,133 @@
+package nesrom
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeHeaderDB writes data to a temporary file and loads it.
+func writeHeaderDB(t *testing.T, name string, data string) HeaderMap {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []uint8(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadHeaderDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestLoadHeaderDB(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8"?>
+<nes20db>
+<game>
+<!-- Game (USA) -->
+<rom size="40960" crc32="0123ABCD"/>
+<pcb mapper="4" submapper="1" mirroring="V" battery="1"/>
+<prgnvram size="8192"/>
+<chrram size="8192"/>
+</game>
+</nes20db>`
+	json := `[{"crc32": "0x0123abcd", "name": "Game (USA)", "mapper": 4, "submapper": 1, "mirroring": "v",
+		"battery": true, "prgNvramSize": 8192, "chrRamSize": 8192}]`
+	want := Header{
+		Name:         "Game (USA)",
+		Mapper:       4,
+		Submapper:    1,
+		Mirroring:    MIR_VER,
+		Battery:      true,
+		PrgNvramSize: 0x2000,
+		ChrRamSize:   0x2000,
+	}
+
+	for name, data := range map[string]string{"db.xml": xml, "db.json": json} {
+		header, ok := writeHeaderDB(t, name, data).Lookup(0x0123ABCD)
+		if !ok {
+			t.Errorf("%s: 0123ABCD not found", name)
+		} else if *header != want {
+			t.Errorf("%s: header %+v, want %+v", name, *header, want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(path, []uint8(`[{"crc32": "nope"}]`), 0644)
+	if _, err := LoadHeaderDB(path); err == nil {
+		t.Error("bad crc32 did not fail")
+	}
+}
+
+// headerFor returns a HeaderMap with header for the PRG and CHR of rom.
+func headerFor(rom *NesRom, header Header) HeaderMap {
+	return HeaderMap{rom.GetPrgChrCrc(): &header}
+}
+
+func TestCheckHeader(t *testing.T) {
+	data := inesRom([]uint8{'N', 'E', 'S', 0x1A, 2, 1, 0x10}, 0x8000, 0x2000)
+	rom, err := loadRom(t, "game.nes", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crc := crc32.ChecksumIEEE(data[16:]); rom.GetPrgChrCrc() != crc {
+		t.Errorf("PRG and CHR CRC %08X, want %08X", rom.GetPrgChrCrc(), crc)
+	}
+
+	if differences, found := rom.CheckHeader(HeaderMap{}); found || differences != nil {
+		t.Errorf("empty database: %q, %v, want not found", differences, found)
+	}
+
+	db := headerFor(rom, Header{Mapper: 4, Mirroring: MIR_VER, Battery: true, PrgNvramSize: 0x2000})
+	differences, found := rom.CheckHeader(db)
+	want := []string{"mapper 1 -> 4", "mirroring H -> V", "battery No -> Yes", "PRG RAM 8K -> 0B", "PRG NVRAM 0B -> 8K"}
+	if !found || fmt.Sprint(differences) != fmt.Sprint(want) {
+		t.Errorf("differences %q, %v, want %q", differences, found, want)
+	}
+	if rom.GetMapper() != 1 || rom.IsNes20() || !bytes.Equal(rom.GetRomData(), data) {
+		t.Error("CheckHeader changed the ROM")
+	}
+
+	// fixing the ROM gives a header that parses as the database entry
+	if fixes, _ := rom.FixHeader(db); fmt.Sprint(fixes) != fmt.Sprint(want) {
+		t.Errorf("fixes %q, want %q", fixes, want)
+	}
+	fixed, err := loadRom(t, "fixed.nes", rom.GetRomData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case !fixed.IsNes20() || fixed.GetMapper() != 4 || fixed.GetMirroring() != MIR_VER || !fixed.IsBatteryRam():
+		t.Errorf("fixed %s mapper %d, mirroring %c, battery %v",
+			fixed.GetFormat(), fixed.GetMapper(), fixed.GetMirroring(), fixed.IsBatteryRam())
+	case fixed.GetPrgNvramSize() != 0x2000 || fixed.GetPrgRamSize() != 0:
+		t.Errorf("fixed PRG RAM %d, NVRAM %d, want 0 and 8192", fixed.GetPrgRamSize(), fixed.GetPrgNvramSize())
+	case !bytes.Equal(fixed.GetRomData()[16:], data[16:]):
+		t.Error("fixed PRG and CHR do not match")
+	}
+	if differences, found := fixed.CheckHeader(db); !found || len(differences) != 0 {
+		t.Errorf("fixed ROM differs: %q", differences)
+	}
+}
+
+func TestFixHeaderOS(t *testing.T) {
+	rom, err := loadRom(t, "os.nes", inesRom([]uint8{'N', 'E', 'S', 0x1A, 2, 1}, 0x8000, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rom.FixHeader(headerFor(rom, Header{Mapper: 255, PrgRamSize: 0x2000, ChrRamSize: 0}))
+	if rom.GetType() != ROM_TYPE_OS || rom.GetPrgAddr() != ADDR_OS_PRG || rom.GetChrAddr() != ADDR_OS_CHR {
+		t.Errorf("mapper 255: type %d, PRG at 0x%X, CHR at 0x%X", rom.GetType(), rom.GetPrgAddr(), rom.GetChrAddr())
+	}
+
+	rom.FixHeader(headerFor(rom, Header{Mapper: 0, PrgRamSize: 0x2000}))
+	if rom.GetType() != ROM_TYPE_NES || rom.GetPrgAddr() != ADDR_PRG || rom.GetChrAddr() != 0 {
+		t.Errorf("mapper 0: type %d, PRG at 0x%X, CHR at 0x%X", rom.GetType(), rom.GetPrgAddr(), rom.GetChrAddr())
+	}
+}
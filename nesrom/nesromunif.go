@@ -0,0 +1,294 @@
+package nesrom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+const UNIF_HEADER_SIZE = 32
+
+// UNIF mirroring values, MIRR chunk.
+const (
+	UNIF_MIR_HOR    uint8 = 0
+	UNIF_MIR_VER    uint8 = 1
+	UNIF_MIR_1SC_A  uint8 = 2
+	UNIF_MIR_1SC_B  uint8 = 3
+	UNIF_MIR_4SC    uint8 = 4
+	UNIF_MIR_MAPPER uint8 = 5
+)
+
+type unifBoard struct {
+	mapper    uint16
+	submapper uint8
+	names     []string
+}
+
+// unifBoards maps UNIF board names, without their "NES-", "UNL-", etc.
+// prefix, to mapper numbers.
+var unifBoards = []unifBoard{
+	{0, 0, []string{"NROM", "NROM-128", "NROM-256", "HROM", "RROM", "RROM-128"}},
+	{1, 0, []string{"SAROM", "SBROM", "SCROM", "SFROM", "SGROM", "SJROM", "SKROM", "SLROM", "SL1ROM", "SL2ROM",
+		"SL3ROM", "SLRROM", "SMROM", "SNROM", "SOROM", "SUROM", "SXROM"}},
+	{1, 5, []string{"SEROM", "SHROM", "SH1ROM"}},
+	{2, 0, []string{"UNROM", "UOROM"}},
+	{3, 0, []string{"CNROM"}},
+	{4, 0, []string{"TBROM", "TEROM", "TFROM", "TGROM", "TKROM", "TLROM", "TL1ROM", "TL2ROM", "TNROM", "TR1ROM",
+		"TSROM", "TVROM", "B4", "HKROM"}},
+	{5, 0, []string{"EKROM", "ELROM", "ETROM", "EWROM"}},
+	{7, 0, []string{"AMROM", "ANROM", "AN1ROM", "AOROM"}},
+	{9, 0, []string{"PNROM", "PEEOROM"}},
+	{10, 0, []string{"FJROM", "FKROM"}},
+	{13, 0, []string{"CPROM"}},
+	{30, 0, []string{"UNROM-512-8", "UNROM-512-16", "UNROM-512-32"}},
+	{34, 1, []string{"NINA-001"}},
+	{34, 2, []string{"BNROM"}},
+	{55, 0, []string{"MARIO1-MALEE2"}},
+	{59, 0, []string{"D1038"}},
+	{66, 0, []string{"GNROM", "MHROM"}},
+	{79, 0, []string{"NINA-003", "NINA-006"}},
+	{90, 0, []string{"TEK90"}},
+	{118, 0, []string{"TKSROM", "TLSROM"}},
+	{119, 0, []string{"TQROM"}},
+	{123, 0, []string{"H2288"}},
+	{125, 0, []string{"LH32"}},
+	{133, 0, []string{"SA-72008"}},
+	{137, 0, []string{"SACHEN-8259D"}},
+	{138, 0, []string{"SACHEN-8259B"}},
+	{139, 0, []string{"SACHEN-8259C"}},
+	{141, 0, []string{"SACHEN-8259A"}},
+	{142, 0, []string{"KS7032"}},
+	{145, 0, []string{"SA-72007"}},
+	{146, 0, []string{"SA-016-1M"}},
+	{147, 0, []string{"TC-U01-1.5M"}},
+	{148, 0, []string{"SA-0037"}},
+	{149, 0, []string{"SA-0036"}},
+	{150, 0, []string{"SACHEN-74LS374N"}},
+	{206, 0, []string{"DEROM", "DE1ROM", "DRROM"}},
+	{215, 0, []string{"8237"}},
+	{342, 0, []string{"COOLGIRL"}},
+	{530, 0, []string{"AX5705"}},
+}
+
+// lookupUnifBoard returns the mapper for a UNIF board name, trying it
+// with and without its prefix.
+func lookupUnifBoard(name string) (unifBoard, bool) {
+	name = strings.ToUpper(name)
+	_, rest, _ := strings.Cut(name, "-")
+
+	for _, board := range unifBoards {
+		for _, boardName := range board.names {
+			if name == boardName || rest == boardName {
+				return board, true
+			}
+		}
+	}
+
+	return unifBoard{}, false
+}
+
+// parseUnif reads the chunks of a UNIF file into n.
+//
+// PRG0-PRGF and CHR0-CHRF are joined in order, unknown chunks are
+// skipped.
+func (n *NesRom) parseUnif(rom []uint8) error {
+	if len(rom) < UNIF_HEADER_SIZE {
+		return fmt.Errorf("UNIF header is truncated")
+	}
+
+	var prg, chr [16][]uint8
+	mirr := UNIF_MIR_HOR
+	for pos := UNIF_HEADER_SIZE; pos < len(rom); {
+		if pos+8 > len(rom) {
+			return fmt.Errorf("UNIF chunk header at 0x%X is truncated", pos)
+		}
+		id := string(rom[pos : pos+4])
+		length := (int)(binary.LittleEndian.Uint32(rom[pos+4:]))
+		pos += 8
+		if length > len(rom)-pos {
+			return fmt.Errorf("UNIF chunk %s at 0x%X is truncated", id, pos-8)
+		}
+		data := rom[pos : pos+length]
+		pos += length
+
+		switch {
+		case id == "MAPR":
+			n.board, _, _ = strings.Cut(string(data), "\x00")
+		case id == "MIRR" && length > 0:
+			mirr = data[0]
+		case id == "BATR" && length > 0:
+			n.batRam = data[0] != 0
+		case strings.HasPrefix(id, "PRG") && isHexDigit(id[3]):
+			prg[hexValue(id[3])] = data
+		case strings.HasPrefix(id, "CHR") && isHexDigit(id[3]):
+			chr[hexValue(id[3])] = data
+		}
+	}
+
+	if n.board == "" {
+		return fmt.Errorf("UNIF file has no MAPR chunk")
+	}
+	board, ok := lookupUnifBoard(n.board)
+	if !ok {
+		return fmt.Errorf("unknown UNIF board %q", n.board)
+	}
+	n.setMapper(board.mapper)
+	n.submapper = board.submapper
+
+	n.prg, n.chr = nil, nil
+	for i := range prg {
+		n.prg = append(n.prg, prg[i]...)
+		n.chr = append(n.chr, chr[i]...)
+	}
+	if len(n.prg) == 0 {
+		return fmt.Errorf("UNIF file has no PRG chunks")
+	}
+	if n.chr == nil {
+		n.chr = []uint8{}
+	}
+	if err := checkLimits((uint32)(len(n.prg)), (uint32)(len(n.chr))); err != nil {
+		return err
+	}
+
+	switch mirr {
+	case UNIF_MIR_VER:
+		n.mirroring = MIR_VER
+	case UNIF_MIR_1SC_A, UNIF_MIR_1SC_B:
+		n.mirroring = MIR_1SC
+	case UNIF_MIR_4SC:
+		n.mirroring = MIR_4SC
+	default:
+		n.mirroring = MIR_HOR
+	}
+
+	n.srmSize = 8192
+
+	return nil
+}
+
+// ToINes returns the ROM as an image with a NES 2.0 header, followed by
+// the PRG and CHR data.
+//
+// PRG and CHR sizes that are neither a multiple of the header units nor
+// expressible as an exponent and multiplier are padded.
+func (n *NesRom) ToINes() []uint8 {
+	h, prgSize, chrSize := n.nes20Header()
+
+	image := make([]uint8, 16+prgSize+chrSize)
+	copy(image, h)
+	copy(image[16:], n.prg)
+	copy(image[16+prgSize:], n.chr)
+
+	return image
+}
+
+// nes20Header builds a NES 2.0 header from the parsed fields, returning
+// it with the padded PRG and CHR sizes it gives.
+func (n *NesRom) nes20Header() ([]uint8, uint32, uint32) {
+	prgLsb, prgMsb, prgSize := nes20SizeFields((uint32)(len(n.prg)), 0x4000)
+	chrLsb, chrMsb, chrSize := nes20SizeFields((uint32)(len(n.chr)), 0x2000)
+
+	h := make([]uint8, INES_HEADER_SIZE)
+	copy(h, "NES\x1a")
+	h[4] = prgLsb
+	h[5] = chrLsb
+	h[6] = (uint8)(n.mapper&0x0f) << 4
+	h[7] = (uint8)(n.mapper&0xf0) | 0x08
+	h[8] = n.submapper<<4 | (uint8)(n.mapper>>8)&0x0f
+	h[9] = chrMsb<<4 | prgMsb
+
+	switch n.mirroring {
+	case MIR_VER:
+		h[6] |= 0x01
+	case MIR_4SC:
+		h[6] |= 0x08
+	case MIR_1SC:
+		// mappers with one-screen mirroring set it themselves
+		n.warn("one-screen mirroring can't be stored in a NES 2.0 header, horizontal is written")
+	}
+	if n.trainer != nil {
+		h[6] |= 0x04
+	}
+
+	prgRam, prgNvram, chrRam, chrNvram := n.ramSizes()
+	if n.batRam {
+		h[6] |= 0x02
+	}
+	h[10] = shiftCount(prgNvram)<<4 | shiftCount(prgRam)
+	h[11] = shiftCount(chrNvram)<<4 | shiftCount(chrRam)
+
+	if n.console >= CONSOLE_EXTENDED {
+		h[7] |= CONSOLE_EXTENDED
+		h[13] = n.console - CONSOLE_EXTENDED
+	} else {
+		h[7] |= n.console
+	}
+	h[12] = n.timing
+	if n.nes20 {
+		h[14] = n.ines[14] & 0x03 // miscellaneous ROMs
+		h[15] = n.ines[15] & 0x3f // default expansion device
+	}
+
+	return h, prgSize, chrSize
+}
+
+// ramSizes returns the PRG RAM, PRG NVRAM, CHR RAM and CHR NVRAM sizes.
+// iNES and UNIF only give the SRM size, which is NVRAM if the ROM has a
+// battery, and CHR RAM is 8K when there is no CHR ROM.
+func (n *NesRom) ramSizes() (uint32, uint32, uint32, uint32) {
+	if n.nes20 {
+		return n.prgRamSize, n.prgNvramSize, n.chrRamSize, n.chrNvramSize
+	}
+
+	var chrRam uint32
+	if len(n.chr) == 0 {
+		chrRam = 0x2000
+	}
+	if n.batRam {
+		return 0, n.srmSize, chrRam, 0
+	}
+	return n.srmSize, 0, chrRam, 0
+}
+
+// nes20SizeFields encodes size for a NES 2.0 header, returning the LSB
+// byte, MSB nibble and the size the data must be padded to.
+func nes20SizeFields(size uint32, unit uint32) (uint8, uint8, uint32) {
+	if size%unit == 0 && size/unit < 0xf00 {
+		return (uint8)(size / unit), (uint8)(size/unit>>8) & 0x0f, size
+	}
+
+	for multiplier := uint32(1); multiplier <= 7; multiplier += 2 {
+		if size%multiplier == 0 && bits.OnesCount32(size/multiplier) == 1 {
+			exponent := bits.TrailingZeros32(size / multiplier)
+			return (uint8)(exponent<<2) | (uint8)(multiplier/2), 0x0f, size
+		}
+	}
+
+	units := (size + unit - 1) / unit
+	return (uint8)(units), (uint8)(units>>8) & 0x0f, units * unit
+}
+
+// shiftCount encodes a RAM size as a NES 2.0 shift count, rounding up.
+func shiftCount(size uint32) uint8 {
+	var shift uint8
+	for size > 0 && 64<<shift < size && shift < 15 {
+		shift++
+	}
+	if size > 0 && shift == 0 {
+		shift = 1
+	}
+
+	return shift
+}
+
+func isHexDigit(c uint8) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'F'
+}
+
+func hexValue(c uint8) int {
+	if c <= '9' {
+		return (int)(c - '0')
+	}
+	return (int)(c-'A') + 10
+}
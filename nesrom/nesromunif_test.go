@@ -0,0 +1,102 @@
+package nesrom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// unifChunk returns a UNIF chunk with the given ID and data.
+func unifChunk(id string, data []uint8) []uint8 {
+	chunk := make([]uint8, 8, 8+len(data))
+	copy(chunk, id)
+	binary.LittleEndian.PutUint32(chunk[4:], (uint32)(len(data)))
+
+	return append(chunk, data...)
+}
+
+// unifRom returns a UNIF file holding the given chunks.
+func unifRom(chunks ...[]uint8) []uint8 {
+	data := make([]uint8, UNIF_HEADER_SIZE)
+	copy(data, "UNIF")
+	binary.LittleEndian.PutUint32(data[4:], 7)
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+
+	return data
+}
+
+func TestUnif(t *testing.T) {
+	prg, chr := romData(0x20000), romData(0x2000)
+	data := unifRom(
+		unifChunk("MAPR", []uint8("NES-AOROM\x00")),
+		unifChunk("PRG0", prg[:0x10000]),
+		unifChunk("PRG1", prg[0x10000:]),
+		unifChunk("CHR0", chr),
+		unifChunk("MIRR", []uint8{UNIF_MIR_1SC_A}),
+		unifChunk("BATR", []uint8{1}),
+	)
+	rom, err := loadRom(t, "game.unf", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case !rom.IsUnif() || rom.GetFormat() != "UNIF" || rom.GetBoard() != "NES-AOROM":
+		t.Errorf("format %s, board %q, want UNIF and NES-AOROM", rom.GetFormat(), rom.GetBoard())
+	case rom.GetMapper() != 7:
+		t.Errorf("mapper = %d, want 7", rom.GetMapper())
+	case !bytes.Equal(rom.GetPrgData(), prg) || !bytes.Equal(rom.GetChrData(), chr):
+		t.Error("PRG and CHR chunks are not joined in order")
+	case rom.GetMirroring() != MIR_1SC || !rom.IsBatteryRam():
+		t.Errorf("mirroring %c, battery %v, want 1 and true", rom.GetMirroring(), rom.IsBatteryRam())
+	case rom.GetName() != "game.nes":
+		t.Errorf("name = %s, want game.nes", rom.GetName())
+	}
+
+	warnings := strings.Join(rom.GetWarnings(), "\n")
+	if strings.Count(warnings, "one-screen") != 1 {
+		t.Errorf("warnings %q, want one about one-screen mirroring", rom.GetWarnings())
+	}
+
+	// the converted image parses as the same ROM
+	image, err := loadRom(t, "game.nes", rom.GetRomData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case !image.IsNes20():
+		t.Errorf("converted format = %s, want NES 2.0", image.GetFormat())
+	case image.GetMapper() != 7 || !image.IsBatteryRam():
+		t.Errorf("converted mapper %d, battery %v, want 7 and true", image.GetMapper(), image.IsBatteryRam())
+	case !bytes.Equal(image.GetPrgData(), prg) || !bytes.Equal(image.GetChrData(), chr):
+		t.Error("converted PRG and CHR do not match")
+	case image.GetRomCrc() != rom.GetRomCrc():
+		t.Errorf("converted ROM ID %08X, want %08X", image.GetRomCrc(), rom.GetRomCrc())
+	}
+}
+
+func TestUnifErrors(t *testing.T) {
+	mapr := unifChunk("MAPR", []uint8("NES-NROM-256\x00"))
+	prg := unifChunk("PRG0", romData(0x8000))
+
+	for _, test := range []struct {
+		name string
+		data []uint8
+		want string
+	}{
+		{"no MAPR", unifRom(prg), "no MAPR"},
+		{"unknown board", unifRom(unifChunk("MAPR", []uint8("UNL-NOTABOARD")), prg), "unknown UNIF board"},
+		{"no PRG", unifRom(mapr), "no PRG"},
+		{"truncated chunk", unifRom(mapr, prg[:0x100]), "truncated"},
+		{"PRG too large", unifRom(mapr, unifChunk("PRG0", make([]uint8, MAX_PRG_SIZE+0x4000))), "PRG ROM"},
+		{"CHR too large", unifRom(mapr, prg, unifChunk("CHR0", make([]uint8, MAX_CHR_SIZE+0x2000))), "CHR ROM"},
+	} {
+		_, err := loadRom(t, "game.unf", test.data)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.want)
+		}
+	}
+}